@@ -0,0 +1,65 @@
+package main
+
+import (
+	"image/color"
+
+	"github.com/shuuuta/shimanuke-chuta/sim"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/text/v2"
+)
+
+// demoIdle is how long the start menu waits for the player before the demo.
+const demoIdle = 60 * 15
+
+// updateIdle counts the frames without any input on the start menu, and
+// starts the demo when the player has been away for long. It reports whether
+// the demo has started.
+func (g *Game) updateIdle() bool {
+	if g.isAnyJustPressed() {
+		g.idle = 0
+		return false
+	}
+	g.idle++
+	if g.idle < demoIdle {
+		return false
+	}
+	g.startDemo()
+	return true
+}
+
+// startDemo starts a run played by the bot in the selected difficulty.
+func (g *Game) startDemo() {
+	g.init(g.config(newSeed()))
+	g.run = runDemo
+	g.controller = sim.NewBot()
+	g.mode = ModeGame
+}
+
+// updateDemoEnd goes back to the start menu after the wait or any input, when
+// the demo is over.
+func (g *Game) updateDemoEnd(wait int) {
+	if g.counter > wait || g.isAnyJustPressed() {
+		g.quit()
+	}
+}
+
+func (g *Game) drawDemo(screen *ebiten.Image) {
+	if g.counter%80 >= 50 {
+		return
+	}
+	op := &text.DrawOptions{}
+	op.GeoM.Translate(screenWidth/2, screenHeight-64)
+	op.ColorScale.ScaleWithColor(color.White)
+	op.LineSpacing = middleFontSize
+	op.PrimaryAlign = text.AlignCenter
+	text.Draw(
+		screen,
+		"デモプレイ",
+		&text.GoTextFace{
+			Source: k8x12sFont,
+			Size:   middleFontSize,
+		},
+		op,
+	)
+}
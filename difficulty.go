@@ -0,0 +1,43 @@
+package main
+
+import (
+	"slices"
+
+	"github.com/shuuuta/shimanuke-chuta/sim"
+)
+
+const difficultyKey = "difficulty"
+
+var difficultyLabels = map[sim.Difficulty]string{
+	sim.DifficultyEasy:   "かんたん",
+	sim.DifficultyNormal: "ふつう",
+	sim.DifficultyHard:   "むずかしい",
+}
+
+func loadDifficulty() sim.Difficulty {
+	d := sim.DifficultyNormal
+	loadJSON(difficultyKey, &d)
+	if !d.Valid() {
+		return sim.DifficultyNormal
+	}
+	return d
+}
+
+// changeDifficulty selects the difficulty next to the current one by delta,
+// and prepares a new run with it.
+func (g *Game) changeDifficulty(delta int) {
+	ds := sim.Difficulties
+	i := slices.Index(ds, g.difficulty)
+	g.difficulty = ds[(i+delta+len(ds))%len(ds)]
+	saveJSON(difficultyKey, g.difficulty)
+	g.init(g.config(newSeed()))
+}
+
+// config returns the config of a run with the seed and the selected
+// difficulty.
+func (g *Game) config(seed uint64) sim.Config {
+	return sim.Config{
+		Seed:       seed,
+		Difficulty: g.difficulty,
+	}
+}
@@ -0,0 +1,18 @@
+//go:build js
+
+package main
+
+import (
+	"syscall/js"
+)
+
+// flagDefault returns the default value of the flag: the parameter of the
+// same name in the query of the page, e.g. ?relay=ws://localhost:8081/.
+func flagDefault(name string) string {
+	params := js.Global().Get("URLSearchParams").New(js.Global().Get("location").Get("search"))
+	v := params.Call("get", name)
+	if v.IsNull() {
+		return ""
+	}
+	return v.String()
+}
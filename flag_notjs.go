@@ -0,0 +1,9 @@
+//go:build !js
+
+package main
+
+// flagDefault returns the default value of the flag, which is always empty on
+// desktops.
+func flagDefault(name string) string {
+	return ""
+}
@@ -14,9 +14,28 @@ const (
 )
 
 func (g *Game) updateGameOver() {
+	if g.run == runRace {
+		g.updateRaceResult()
+		return
+	}
+	if g.run == runOnline {
+		g.updateOnlineResult()
+		return
+	}
+	if g.run == runDemo {
+		g.updateDemoEnd(gameOverWait)
+		return
+	}
+	if g.counter == seGameOverDelay {
+		g.sound.playSE("gameover")
+	}
+	if g.isReplaySaveJustPressed() {
+		g.saveReplay()
+		return
+	}
 	if g.counter > gameOverWait && g.isSelectJustPressed() {
 		g.counter = 0
-		g.init()
+		g.init(g.config(newSeed()))
 		g.mode = ModeStartMenu
 	}
 }
@@ -24,22 +43,42 @@ func (g *Game) updateGameOver() {
 func (g *Game) drawGameOver(screen *ebiten.Image) {
 	vector.DrawFilledRect(screen, 0, 0, screenWidth, screenHeight, color.RGBA{0, 0, 0, 50}, false)
 
-	title := g.location
+	stages := g.world.Stages
+	title := g.world.Location
 	afterTitle := "到達"
-	dist := fmt.Sprintf("%.1fkm", float64(getTravelDistance(g.y16))/1000)
+	dist := fmt.Sprintf("%.1fkm", float64(g.world.Distance())/1000)
 
-	if title == g.stages[0].name {
+	if title == stages[0].Name {
 		title = "島抜け失敗"
 		afterTitle = ""
 	}
 
-	if title == g.stages[len(g.stages)-1].name {
-		title = "島抜け成功!!"
-		afterTitle = ""
+	if g.run == runPractice {
+		afterTitle = "練習 " + afterTitle
+	}
+	if g.replay != nil {
+		afterTitle = "リプレイ " + afterTitle
 	}
 
 	textY := 128.0
 
+	if g.newRecord && g.counter%60 < 40 {
+		op := &text.DrawOptions{}
+		op.GeoM.Translate(screenWidth/2, textY-middleFontSize-16)
+		op.ColorScale.ScaleWithColor(color.RGBA{0xff, 0xd7, 0x00, 0xff})
+		op.LineSpacing = middleFontSize
+		op.PrimaryAlign = text.AlignCenter
+		text.Draw(
+			screen,
+			"NEW RECORD!",
+			&text.GoTextFace{
+				Source: misakiFont,
+				Size:   middleFontSize,
+			},
+			op,
+		)
+	}
+
 	op := &text.DrawOptions{}
 	op.GeoM.Translate(screenWidth/2, textY)
 	op.ColorScale.ScaleWithColor(color.White)
@@ -89,8 +128,32 @@ func (g *Game) drawGameOver(screen *ebiten.Image) {
 		op,
 	)
 
+	textY += middleFontSize + 16
+
+	if rec, ok := g.records[g.recordMode()]; ok && g.replay == nil {
+		label := rec.String()
+		if g.run == runDaily {
+			label = "今日の" + label
+		}
+
+		op = &text.DrawOptions{}
+		op.GeoM.Translate(screenWidth/2, textY)
+		op.ColorScale.ScaleWithColor(color.White)
+		op.LineSpacing = fontSize
+		op.PrimaryAlign = text.AlignCenter
+		text.Draw(
+			screen,
+			label,
+			&text.GoTextFace{
+				Source: misakiFont,
+				Size:   fontSize,
+			},
+			op,
+		)
+	}
+
 	if g.counter > gameOverWait && (g.counter-gameOverWait)%100 < 50 {
-		textY += middleFontSize + 64
+		textY += fontSize + 48
 
 		op = &text.DrawOptions{}
 		op.GeoM.Translate(screenWidth/2, textY)
@@ -107,4 +170,34 @@ func (g *Game) drawGameOver(screen *ebiten.Image) {
 			op,
 		)
 	}
+
+	g.drawReplaySave(screen)
+	g.drawScoreStatus(screen)
+}
+
+// drawReplaySave draws the button to save the replay of the run that has just
+// ended, or the result of saving it.
+func (g *Game) drawReplaySave(screen *ebiten.Image) {
+	replayText := "S: リプレイを保存"
+	if g.replay != nil {
+		replayText = ""
+	} else if g.replayMessage != "" {
+		replayText = g.replayMessage
+	}
+
+	op := &text.DrawOptions{}
+	op.GeoM.Translate(screenWidth/2, float64(replaySaveButton.Min.Y+replaySaveButton.Dy()/2))
+	op.ColorScale.ScaleWithColor(color.White)
+	op.LineSpacing = fontSize
+	op.PrimaryAlign = text.AlignCenter
+	op.SecondaryAlign = text.AlignCenter
+	text.Draw(
+		screen,
+		replayText,
+		&text.GoTextFace{
+			Source: misakiFont,
+			Size:   fontSize,
+		},
+		op,
+	)
 }
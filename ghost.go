@@ -0,0 +1,167 @@
+package main
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/shuuuta/shimanuke-chuta/sim"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/text/v2"
+)
+
+const ghostsKey = "ghosts"
+
+// ghostRun is where the player was in every frame of their best run of a
+// mode, to be raced against on the same seed.
+type ghostRun struct {
+	Seed     uint64 `json:"seed"`
+	Distance int    `json:"distance"`
+	// X0 and Y0 are the position of the player in pixels at the start, and
+	// DX and DY are how far the player moved in each frame as int8.
+	X0 int    `json:"x0"`
+	Y0 int    `json:"y0"`
+	DX []byte `json:"dx"`
+	DY []byte `json:"dy"`
+
+	// xs and ys are the positions after each frame, computed from DX and DY.
+	xs, ys []int
+}
+
+func newGhostRun(w *sim.World) *ghostRun {
+	return &ghostRun{
+		Seed: w.Seed,
+		X0:   w.X16 / 16,
+		Y0:   w.Y16 / 16,
+	}
+}
+
+// record appends the position of the player after a frame.
+func (r *ghostRun) record(w *sim.World) {
+	x, y := r.position(len(r.DX))
+	r.DX = append(r.DX, byte(int8(w.X16/16-x)))
+	r.DY = append(r.DY, byte(int8(w.Y16/16-y)))
+	r.xs = append(r.xs, w.X16/16)
+	r.ys = append(r.ys, w.Y16/16)
+	r.Distance = w.Distance()
+}
+
+// position returns the position of the player after the frames, which must
+// not be more than the frames of the run.
+func (r *ghostRun) position(frame int) (x, y int) {
+	if len(r.xs) != len(r.DX) {
+		r.xs, r.ys = nil, nil
+		x, y := r.X0, r.Y0
+		for i := range r.DX {
+			x += int(int8(r.DX[i]))
+			y += int(int8(r.DY[i]))
+			r.xs = append(r.xs, x)
+			r.ys = append(r.ys, y)
+		}
+	}
+	if frame == 0 {
+		return r.X0, r.Y0
+	}
+	return r.xs[frame-1], r.ys[frame-1]
+}
+
+// frames returns the number of the frames of the run.
+func (r *ghostRun) frames() int {
+	return len(r.DX)
+}
+
+// ghostRuns holds the ghost of the best run of each mode.
+type ghostRuns map[string]*ghostRun
+
+func loadGhostRuns() ghostRuns {
+	gs := ghostRuns{}
+	loadJSON(ghostsKey, &gs)
+	for mode, r := range gs {
+		if r == nil || len(r.DX) != len(r.DY) {
+			delete(gs, mode)
+		}
+	}
+	return gs
+}
+
+func (gs ghostRuns) save() {
+	saveJSON(ghostsKey, gs)
+}
+
+// update keeps the run as the ghost of the mode if it has gone farther.
+func (gs ghostRuns) update(mode string, r *ghostRun) {
+	if old, ok := gs[mode]; ok && old.Distance >= r.Distance {
+		return
+	}
+	gs[mode] = r
+	gs.save()
+}
+
+// pruneDaily removes the ghosts of the daily challenges except the ones of
+// the date.
+func (gs ghostRuns) pruneDaily(date string) {
+	for mode := range gs {
+		if isStaleDaily(mode, date) {
+			delete(gs, mode)
+		}
+	}
+}
+
+// ghostMode returns the mode whose ghost the current run records and races
+// against, or "" when it has none.
+func (g *Game) ghostMode() string {
+	switch g.run {
+	case runNormal, runDaily:
+		return g.recordMode()
+	}
+	return ""
+}
+
+// startGhostRace starts a run on the seed of the best run in the selected
+// difficulty, against its ghost.
+func (g *Game) startGhostRace() {
+	r := g.ghostRuns[normalRecordMode(g.difficulty)]
+	g.init(g.config(r.Seed))
+	g.ghost = r
+	g.mode = ModeGame
+}
+
+// drawGhost draws the ghost of the best run where it was in the same frame.
+func (g *Game) drawGhost(screen *ebiten.Image) {
+	w := g.world
+	if w.Frame > g.ghost.frames() {
+		// The ghost has sunk.
+		return
+	}
+	x, y := g.ghost.position(w.Frame)
+	sy := w.Y16/16 - w.CameraY - (y - w.Y16/16)
+	drawGhostBoat(screen, float64(x-g.cameraX), float64(sy), 0.4, "BEST")
+}
+
+// drawGhostBoat draws a translucent boat with the label over it. Boats far
+// ahead or behind stay at the edges of the screen.
+func drawGhostBoat(screen *ebiten.Image, x, y float64, alpha float32, label string) {
+	y = min(max(y, hudFontSize), screenHeight-playerHeight)
+
+	op := &ebiten.DrawImageOptions{}
+	op.GeoM.Translate(x, y)
+	op.ColorScale.ScaleAlpha(alpha)
+	screen.DrawImage(PlayerImage.SubImage(image.Rect(0, 0, playerWidth, playerHeight)).(*ebiten.Image), op)
+
+	textOp := &text.DrawOptions{}
+	textOp.GeoM.Translate(x+playerWidth/2, y-4)
+	textOp.ColorScale.ScaleWithColor(color.White)
+	textOp.ColorScale.ScaleAlpha(alpha * 2)
+	textOp.LineSpacing = hudFontSize
+	textOp.PrimaryAlign = text.AlignCenter
+	textOp.SecondaryAlign = text.AlignEnd
+	text.Draw(
+		screen,
+		label,
+		&text.GoTextFace{
+			Source: misakiFont,
+			Size:   hudFontSize,
+		},
+		textOp,
+	)
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/text/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+const (
+	// goalSail is how long the player sails away off the screen.
+	goalSail = 120
+	goalWait = 180
+)
+
+func (g *Game) updateGoal() {
+	if g.run == runDemo {
+		g.updateDemoEnd(goalWait)
+		return
+	}
+	if g.isReplaySaveJustPressed() {
+		g.saveReplay()
+		return
+	}
+	if g.counter > goalWait && g.isSelectJustPressed() {
+		g.counter = 0
+		g.init(g.config(newSeed()))
+		g.mode = ModeStartMenu
+	}
+}
+
+// goalSailOffset returns how far the player has sailed on from the goal. The
+// player sails to the top of the screen, faster and faster.
+func (g *Game) goalSailOffset() float64 {
+	t := float64(min(g.counter, goalSail)) / goalSail
+	return screenHeight * t * t
+}
+
+func (g *Game) drawGoal(screen *ebiten.Image) {
+	g.drawPlayer(screen)
+
+	if g.counter < goalSail {
+		return
+	}
+
+	a := uint8(min(g.counter-goalSail, 50))
+	vector.DrawFilledRect(screen, 0, 0, screenWidth, screenHeight, color.RGBA{0, 0, 0, a}, false)
+
+	textY := 128.0
+
+	if g.newRecord && g.counter%60 < 40 {
+		op := &text.DrawOptions{}
+		op.GeoM.Translate(screenWidth/2, textY-middleFontSize-16)
+		op.ColorScale.ScaleWithColor(color.RGBA{0xff, 0xd7, 0x00, 0xff})
+		op.LineSpacing = middleFontSize
+		op.PrimaryAlign = text.AlignCenter
+		text.Draw(
+			screen,
+			"NEW RECORD!",
+			&text.GoTextFace{
+				Source: misakiFont,
+				Size:   middleFontSize,
+			},
+			op,
+		)
+	}
+
+	title := "島抜け成功!!"
+	if g.run == runPractice {
+		title = "練習 " + title
+	}
+	if g.replay != nil {
+		title = "リプレイ " + title
+	}
+	top := &text.DrawOptions{}
+	top.GeoM.Translate(screenWidth/2, textY)
+	top.ColorScale.ScaleWithColor(color.RGBA{0xff, 0xd7, 0x00, 0xff})
+	top.LineSpacing = middleFontSize
+	top.PrimaryAlign = text.AlignCenter
+	text.Draw(
+		screen,
+		title,
+		&text.GoTextFace{
+			Source: k8x12sFont,
+			Size:   middleFontSize,
+		},
+		top,
+	)
+
+	textY += middleFontSize + 24
+
+	seconds := g.world.Frame / ebiten.DefaultTPS
+	tp := &text.DrawOptions{}
+	tp.GeoM.Translate(screenWidth/2, textY)
+	tp.ColorScale.ScaleWithColor(color.White)
+	tp.LineSpacing = fontSize * 1.5
+	tp.PrimaryAlign = text.AlignCenter
+	text.Draw(
+		screen,
+		fmt.Sprintf("%s 到着\n%.1fkm  %d分%02d秒", g.world.Location, float64(g.world.Distance())/1000, seconds/60, seconds%60),
+		&text.GoTextFace{
+			Source: misakiFont,
+			Size:   fontSize,
+		},
+		tp,
+	)
+
+	textY += fontSize*3 + 16
+
+	if rec, ok := g.records[g.recordMode()]; ok && g.replay == nil {
+		label := rec.String()
+		if g.run == runDaily {
+			label = "今日の" + label
+		}
+
+		op := &text.DrawOptions{}
+		op.GeoM.Translate(screenWidth/2, textY)
+		op.ColorScale.ScaleWithColor(color.White)
+		op.LineSpacing = fontSize
+		op.PrimaryAlign = text.AlignCenter
+		text.Draw(
+			screen,
+			label,
+			&text.GoTextFace{
+				Source: misakiFont,
+				Size:   fontSize,
+			},
+			op,
+		)
+	}
+
+	if g.counter > goalWait && (g.counter-goalWait)%100 < 50 {
+		textY += fontSize + 48
+
+		op := &text.DrawOptions{}
+		op.GeoM.Translate(screenWidth/2, textY)
+		op.ColorScale.ScaleWithColor(color.White)
+		op.LineSpacing = fontSize
+		op.PrimaryAlign = text.AlignCenter
+		text.Draw(
+			screen,
+			"Tap to start menu",
+			&text.GoTextFace{
+				Source: misakiFont,
+				Size:   fontSize,
+			},
+			op,
+		)
+	}
+
+	g.drawReplaySave(screen)
+	g.drawScoreStatus(screen)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/shuuuta/shimanuke-chuta/sim"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// showHitbox draws the collision masks over the sprites. It is toggled with
+// the H key in dev builds.
+var showHitbox = false
+
+var maskImages = map[*sim.Mask]*ebiten.Image{}
+
+// maskImage returns the mask as an image, white where it is solid.
+func maskImage(m *sim.Mask) *ebiten.Image {
+	if img, ok := maskImages[m]; ok {
+		return img
+	}
+	rgba := image.NewRGBA(image.Rect(0, 0, m.Width, m.Height))
+	for y := 0; y < m.Height; y++ {
+		for x := 0; x < m.Width; x++ {
+			if m.At(x, y) {
+				rgba.Set(x, y, color.White)
+			}
+		}
+	}
+	img := ebiten.NewImageFromImage(rgba)
+	maskImages[m] = img
+	return img
+}
+
+func (g *Game) drawHitbox(screen *ebiten.Image) {
+	op := &ebiten.DrawImageOptions{}
+	op.ColorScale.ScaleWithColor(color.RGBA{0x80, 0, 0, 0x80})
+	for _, s := range g.world.Surfs {
+		y := float64(s.Y + g.world.CameraY)
+		if y < -surfHeight || y > screenHeight {
+			continue
+		}
+		for _, t := range s.Tiles() {
+			op.GeoM.Reset()
+			op.GeoM.Translate(float64(t.X), y)
+			screen.DrawImage(maskImage(sim.SurfMask(t.SX)), op)
+		}
+	}
+
+	for _, o := range g.world.Obstacles {
+		m := o.Mask()
+		if m == nil {
+			continue
+		}
+		op.GeoM.Reset()
+		op.GeoM.Translate(float64(o.X16/16), float64(o.Y+g.world.CameraY))
+		screen.DrawImage(maskImage(m), op)
+	}
+
+	if g.mode == ModeStartMenu || g.mode == ModeSettings {
+		return
+	}
+	col, row := g.world.PlayerFrame()
+	op = &ebiten.DrawImageOptions{}
+	op.GeoM = g.playerGeoM()
+	op.ColorScale.ScaleWithColor(color.RGBA{0, 0, 0x80, 0x80})
+	screen.DrawImage(maskImage(sim.PlayerMask(col, row)), op)
+}
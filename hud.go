@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"slices"
+
+	"github.com/shuuuta/shimanuke-chuta/sim"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/text/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+const (
+	progressBarX0 = 32
+	progressBarX1 = screenWidth - 80
+	progressBarY  = 52
+
+	hudFontSize = 16
+
+	bannerY        = 192
+	bannerHeight   = 96
+	bannerSlideIn  = 20
+	bannerSlideOut = 120
+	bannerDuration = 150
+)
+
+// banner is the arrival banner shown in the world that has entered a stage.
+type banner struct {
+	world *sim.World
+	stage int
+	frame int
+}
+
+// startBanner shows the arrival banner of the stage the world has just
+// entered, in place of the last one of the world.
+func (g *Game) startBanner() {
+	b := banner{
+		world: g.world,
+		stage: stageIndex(g.world.Stages, g.world.Location),
+		frame: g.world.Frame,
+	}
+	for i := range g.banners {
+		if g.banners[i].world == g.world {
+			g.banners[i] = b
+			return
+		}
+	}
+	g.banners = append(g.banners, b)
+}
+
+// drawProgress draws the route with the markers of the islands and the
+// player's position on it.
+func (g *Game) drawProgress(screen *ebiten.Image) {
+	stages := g.world.Stages
+	current := stageIndex(stages, g.world.Location)
+
+	// In the endless mode the route grows as the player goes, so the bar
+	// shows the distance of the route up to the next waypoint.
+	route := g.world.GoalDist * 1000
+	lo, hi := 0, route
+	if g.world.Endless && current+1 < len(stages) {
+		hi = max(route, stages[current+1].Dist*1000)
+		lo = hi - route
+	}
+	toX := func(dist int) float32 {
+		r := min(float32(dist-lo)/float32(hi-lo), 1)
+		return progressBarX0 + (progressBarX1-progressBarX0)*r
+	}
+
+	vector.DrawFilledRect(screen, progressBarX0, progressBarY-2, progressBarX1-progressBarX0, 4, color.RGBA{0, 0, 0, 96}, false)
+	vector.DrawFilledRect(screen, progressBarX0, progressBarY-2, toX(g.world.Distance())-progressBarX0, 4, color.White, false)
+
+	for i, s := range stages {
+		if s.Dist*1000 < lo || s.Dist*1000 > hi {
+			continue
+		}
+		clr := color.RGBA{0x80, 0x80, 0x80, 0xff}
+		if i <= current {
+			clr = color.RGBA{0xff, 0xd7, 0x00, 0xff}
+		}
+		vector.DrawFilledCircle(screen, toX(s.Dist*1000), progressBarY, 4, clr, false)
+	}
+	vector.DrawFilledCircle(screen, toX(g.world.Distance()), progressBarY, 6, color.White, false)
+
+	label := g.world.Location
+	if current+1 < len(stages) {
+		next := stages[current+1]
+		label = fmt.Sprintf("%s → %s %.1fkm", g.world.Location, next.Name, float64(next.Dist*1000-g.world.Distance())/1000)
+	}
+	op := &text.DrawOptions{}
+	op.GeoM.Translate(progressBarX0, progressBarY+8)
+	op.ColorScale.ScaleWithColor(color.White)
+	op.LineSpacing = hudFontSize
+	text.Draw(
+		screen,
+		label,
+		&text.GoTextFace{
+			Source: misakiFont,
+			Size:   hudFontSize,
+		},
+		op,
+	)
+}
+
+// drawBanner draws the arrival banner sliding through the screen.
+func (g *Game) drawBanner(screen *ebiten.Image) {
+	i := slices.IndexFunc(g.banners, func(b banner) bool {
+		return b.world == g.world
+	})
+	if i < 0 || g.banners[i].stage <= 0 {
+		return
+	}
+	t := g.world.Frame - g.banners[i].frame
+	if t >= bannerDuration {
+		return
+	}
+
+	offset := 0.0
+	switch {
+	case t < bannerSlideIn:
+		r := 1 - float64(t)/bannerSlideIn
+		offset = screenWidth * r * r
+	case t >= bannerSlideOut:
+		r := float64(t-bannerSlideOut) / (bannerDuration - bannerSlideOut)
+		offset = -screenWidth * r * r
+	}
+
+	s := g.world.Stages[g.banners[i].stage]
+	vector.DrawFilledRect(screen, float32(offset), bannerY, screenWidth, bannerHeight, color.RGBA{0, 0, 0, 128}, false)
+
+	op := &text.DrawOptions{}
+	op.GeoM.Translate(screenWidth/2+offset, bannerY+12)
+	op.ColorScale.ScaleWithColor(color.White)
+	op.LineSpacing = middleFontSize
+	op.PrimaryAlign = text.AlignCenter
+	text.Draw(
+		screen,
+		s.Name+" 到達!",
+		&text.GoTextFace{
+			Source: k8x12sFont,
+			Size:   middleFontSize,
+		},
+		op,
+	)
+
+	op = &text.DrawOptions{}
+	op.GeoM.Translate(screenWidth/2+offset, bannerY+bannerHeight-hudFontSize-12)
+	op.ColorScale.ScaleWithColor(color.White)
+	op.LineSpacing = hudFontSize
+	op.PrimaryAlign = text.AlignCenter
+	text.Draw(
+		screen,
+		fmt.Sprintf("速度 %d  すき間 %d  波の間隔 %d", g.world.Speed, g.world.SurfGap, g.world.SurfInterval),
+		&text.GoTextFace{
+			Source: misakiFont,
+			Size:   hudFontSize,
+		},
+		op,
+	)
+}
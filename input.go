@@ -0,0 +1,289 @@
+package main
+
+import (
+	"image"
+	"slices"
+	"strings"
+
+	"github.com/shuuuta/shimanuke-chuta/sim"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+const bindingsKey = "bindings"
+
+// action is what the player can do with keys and gamepad buttons.
+type action int
+
+const (
+	actionLeft action = iota
+	actionRight
+	actionUp
+	actionDown
+	actionSelect
+	actionPause
+
+	actionCount
+)
+
+var actionNames = [actionCount]string{
+	actionLeft:   "左",
+	actionRight:  "右",
+	actionUp:     "上",
+	actionDown:   "下",
+	actionSelect: "決定",
+	actionPause:  "一時停止",
+}
+
+// binding is the keys and the standard gamepad buttons for an action.
+type binding struct {
+	Keys    []ebiten.Key                   `json:"keys"`
+	Buttons []ebiten.StandardGamepadButton `json:"buttons"`
+}
+
+type bindings [actionCount]binding
+
+func defaultBindings() bindings {
+	return bindings{
+		actionLeft: {
+			Keys:    []ebiten.Key{ebiten.KeyArrowLeft},
+			Buttons: []ebiten.StandardGamepadButton{ebiten.StandardGamepadButtonLeftLeft, ebiten.StandardGamepadButtonFrontTopLeft},
+		},
+		actionRight: {
+			Keys:    []ebiten.Key{ebiten.KeyArrowRight},
+			Buttons: []ebiten.StandardGamepadButton{ebiten.StandardGamepadButtonLeftRight, ebiten.StandardGamepadButtonFrontTopRight},
+		},
+		actionUp: {
+			Keys:    []ebiten.Key{ebiten.KeyArrowUp},
+			Buttons: []ebiten.StandardGamepadButton{ebiten.StandardGamepadButtonLeftTop},
+		},
+		actionDown: {
+			Keys:    []ebiten.Key{ebiten.KeyArrowDown},
+			Buttons: []ebiten.StandardGamepadButton{ebiten.StandardGamepadButtonLeftBottom},
+		},
+		actionSelect: {
+			Keys:    []ebiten.Key{ebiten.KeySpace, ebiten.KeyEnter},
+			Buttons: []ebiten.StandardGamepadButton{ebiten.StandardGamepadButtonRightBottom},
+		},
+		actionPause: {
+			Keys:    []ebiten.Key{ebiten.KeyEscape, ebiten.KeyP},
+			Buttons: []ebiten.StandardGamepadButton{ebiten.StandardGamepadButtonCenterRight},
+		},
+	}
+}
+
+// bind binds the key or the gamepad button of nb to a in place of the ones of
+// the same device. An action that had it loses it, and gets the old ones of a
+// if it has nothing left, so that no key or button does two things. The sound
+// keys can't be bound, and bind reports false for them.
+func (b *bindings) bind(a action, nb binding) bool {
+	if len(nb.Keys) > 0 {
+		if slices.Contains(soundKeys, nb.Keys[0]) {
+			return false
+		}
+		for o := range b {
+			if action(o) != a {
+				b[o].Keys = without(b[o].Keys, nb.Keys[0], b[a].Keys)
+			}
+		}
+		b[a].Keys = nb.Keys
+		return true
+	}
+	for o := range b {
+		if action(o) != a {
+			b[o].Buttons = without(b[o].Buttons, nb.Buttons[0], b[a].Buttons)
+		}
+	}
+	b[a].Buttons = nb.Buttons
+	return true
+}
+
+// without returns s without v, or instead when nothing is left of s.
+func without[T comparable](s []T, v T, instead []T) []T {
+	if !slices.Contains(s, v) {
+		return s
+	}
+	s = slices.DeleteFunc(slices.Clone(s), func(x T) bool {
+		return x == v
+	})
+	if len(s) == 0 {
+		return slices.Clone(instead)
+	}
+	return s
+}
+
+func loadBindings() bindings {
+	b := defaultBindings()
+	loadJSON(bindingsKey, &b)
+	return b
+}
+
+func (b bindings) save() {
+	saveJSON(bindingsKey, b)
+}
+
+var gamepadButtonNames = map[ebiten.StandardGamepadButton]string{
+	ebiten.StandardGamepadButtonRightBottom:      "A",
+	ebiten.StandardGamepadButtonRightRight:       "B",
+	ebiten.StandardGamepadButtonRightLeft:        "X",
+	ebiten.StandardGamepadButtonRightTop:         "Y",
+	ebiten.StandardGamepadButtonFrontTopLeft:     "L",
+	ebiten.StandardGamepadButtonFrontTopRight:    "R",
+	ebiten.StandardGamepadButtonFrontBottomLeft:  "ZL",
+	ebiten.StandardGamepadButtonFrontBottomRight: "ZR",
+	ebiten.StandardGamepadButtonCenterLeft:       "SELECT",
+	ebiten.StandardGamepadButtonCenterRight:      "START",
+	ebiten.StandardGamepadButtonLeftStick:        "LS",
+	ebiten.StandardGamepadButtonRightStick:       "RS",
+	ebiten.StandardGamepadButtonLeftTop:          "十字上",
+	ebiten.StandardGamepadButtonLeftBottom:       "十字下",
+	ebiten.StandardGamepadButtonLeftLeft:         "十字左",
+	ebiten.StandardGamepadButtonLeftRight:        "十字右",
+	ebiten.StandardGamepadButtonCenterCenter:     "HOME",
+}
+
+func (b binding) String() string {
+	var names []string
+	for _, k := range b.Keys {
+		names = append(names, k.String())
+	}
+	for _, btn := range b.Buttons {
+		names = append(names, gamepadButtonNames[btn])
+	}
+	if len(names) == 0 {
+		return "-"
+	}
+	return strings.Join(names, " / ")
+}
+
+func (b binding) isJustPressed(gamepadIDs []ebiten.GamepadID) bool {
+	for _, k := range b.Keys {
+		if inpututil.IsKeyJustPressed(k) {
+			return true
+		}
+	}
+	for _, id := range gamepadIDs {
+		if b.isButtonJustPressed(id) {
+			return true
+		}
+	}
+	return false
+}
+
+// isButtonJustPressed is like isJustPressed only for the buttons of the gamepad.
+func (b binding) isButtonJustPressed(id ebiten.GamepadID) bool {
+	if !ebiten.IsStandardGamepadLayoutAvailable(id) {
+		return false
+	}
+	for _, btn := range b.Buttons {
+		if inpututil.IsStandardGamepadButtonJustPressed(id, btn) {
+			return true
+		}
+	}
+	return false
+}
+
+// updateInput polls the devices for this frame.
+func (g *Game) updateInput() {
+	g.touchIDs = inpututil.AppendJustPressedTouchIDs(g.touchIDs[:0])
+	g.gamepadIDs = ebiten.AppendGamepadIDs(g.gamepadIDs[:0])
+}
+
+// isActionJustPressed reports whether a key or a gamepad button bound to a
+// has been pressed in this frame.
+func (g *Game) isActionJustPressed(a action) bool {
+	return g.bindings[a].isJustPressed(g.gamepadIDs)
+}
+
+// justPressedKeyOrButton returns the binding of a key or a gamepad button
+// pressed in this frame, to be bound to an action.
+func (g *Game) justPressedKeyOrButton() (binding, bool) {
+	if keys := inpututil.AppendJustPressedKeys(nil); len(keys) > 0 {
+		return binding{Keys: keys[:1]}, true
+	}
+	for _, id := range g.gamepadIDs {
+		if !ebiten.IsStandardGamepadLayoutAvailable(id) {
+			continue
+		}
+		for btn := ebiten.StandardGamepadButton(0); btn <= ebiten.StandardGamepadButtonMax; btn++ {
+			if inpututil.IsStandardGamepadButtonJustPressed(id, btn) {
+				return binding{Buttons: []ebiten.StandardGamepadButton{btn}}, true
+			}
+		}
+	}
+	return binding{}, false
+}
+
+// isAnyJustPressed reports whether any key, button, click or tap is just
+// pressed.
+func (g *Game) isAnyJustPressed() bool {
+	if _, ok := g.justPressedKeyOrButton(); ok {
+		return true
+	}
+	_, ok := g.justPressedPosition()
+	return ok
+}
+
+// justPressedPosition returns the position of a click or a tap in this frame.
+func (g *Game) justPressedPosition() (image.Point, bool) {
+	if inpututil.IsMouseButtonJustReleased(ebiten.MouseButtonLeft) {
+		x, y := ebiten.CursorPosition()
+		return image.Pt(x, y), true
+	}
+	if len(g.touchIDs) > 0 {
+		x, y := ebiten.TouchPosition(g.touchIDs[0])
+		return image.Pt(x, y), true
+	}
+	return image.Point{}, false
+}
+
+func (g *Game) isSelectJustPressed() bool {
+	if g.isActionJustPressed(actionSelect) {
+		return true
+	}
+	_, ok := g.justPressedPosition()
+	return ok
+}
+
+func (g *Game) isRightJustPressed() bool {
+	if g.isActionJustPressed(actionRight) {
+		return true
+	}
+	p, ok := g.justPressedPosition()
+	return ok && p.X >= screenWidth/2
+}
+
+func (g *Game) isLeftJustPressed() bool {
+	if g.isActionJustPressed(actionLeft) {
+		return true
+	}
+	p, ok := g.justPressedPosition()
+	return ok && p.X < screenWidth/2
+}
+
+func (g *Game) isPauseJustPressed() bool {
+	if g.isActionJustPressed(actionPause) {
+		return true
+	}
+	if !ebiten.IsFocused() {
+		return true
+	}
+	p, ok := g.justPressedPosition()
+	return ok && p.In(pauseButton)
+}
+
+// deviceController is the player's input from the keyboard, touches and
+// gamepads.
+type deviceController struct {
+	g *Game
+}
+
+// Input implements sim.Controller.
+func (c deviceController) Input(*sim.World) sim.Input {
+	return sim.Input{
+		Left:   c.g.isLeftJustPressed(),
+		Right:  c.g.isRightJustPressed(),
+		Select: c.g.isSelectJustPressed(),
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+func TestBind(t *testing.T) {
+	b := defaultBindings()
+
+	// The key of another action moves, and the action gets the old key.
+	if !b.bind(actionLeft, binding{Keys: []ebiten.Key{ebiten.KeyArrowRight}}) {
+		t.Fatal("arrow right is refused")
+	}
+	if want := []ebiten.Key{ebiten.KeyArrowRight}; !reflect.DeepEqual(b[actionLeft].Keys, want) {
+		t.Errorf("left: got %v, want %v", b[actionLeft].Keys, want)
+	}
+	if want := []ebiten.Key{ebiten.KeyArrowLeft}; !reflect.DeepEqual(b[actionRight].Keys, want) {
+		t.Errorf("right: got %v, want %v", b[actionRight].Keys, want)
+	}
+
+	// An action with another key left keeps it.
+	b.bind(actionLeft, binding{Keys: []ebiten.Key{ebiten.KeyP}})
+	if want := []ebiten.Key{ebiten.KeyEscape}; !reflect.DeepEqual(b[actionPause].Keys, want) {
+		t.Errorf("pause: got %v, want %v", b[actionPause].Keys, want)
+	}
+
+	// Buttons move the same way.
+	b.bind(actionSelect, binding{Buttons: []ebiten.StandardGamepadButton{ebiten.StandardGamepadButtonLeftLeft}})
+	if want := []ebiten.StandardGamepadButton{ebiten.StandardGamepadButtonFrontTopLeft}; !reflect.DeepEqual(b[actionLeft].Buttons, want) {
+		t.Errorf("left buttons: got %v, want %v", b[actionLeft].Buttons, want)
+	}
+
+	// The sound keys are refused.
+	for _, k := range soundKeys {
+		old := b
+		if b.bind(actionUp, binding{Keys: []ebiten.Key{k}}) {
+			t.Errorf("%v is bound", k)
+		}
+		if !reflect.DeepEqual(b, old) {
+			t.Errorf("%v changed the bindings", k)
+		}
+	}
+
+	// No key or button does two things.
+	seen := map[any]action{}
+	for a := range b {
+		for _, k := range b[a].Keys {
+			if o, ok := seen[k]; ok {
+				t.Errorf("%v is bound to %d and %d", k, o, a)
+			}
+			seen[k] = action(a)
+		}
+		for _, btn := range b[a].Buttons {
+			if o, ok := seen[btn]; ok {
+				t.Errorf("%v is bound to %d and %d", btn, o, a)
+			}
+			seen[btn] = action(a)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+
+	"github.com/shuuuta/shimanuke-chuta/sim"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/text/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+const (
+	itemEffectDuration = 40
+
+	powerUpX    = progressBarX0
+	powerUpY    = 84
+	powerUpIcon = 24
+	powerUpBar  = 64
+)
+
+var itemLabels = map[sim.ItemKind]string{
+	sim.ItemShield: "シールド",
+	sim.ItemCalm:   "なぎ",
+	sim.ItemBoost:  "ブースト",
+}
+
+var itemColors = map[sim.ItemKind]color.RGBA{
+	sim.ItemShield: {0xf2, 0xc2, 0x30, 0xff},
+	sim.ItemCalm:   {0x44, 0xc8, 0xa4, 0xff},
+	sim.ItemBoost:  {0xf0, 0x64, 0x34, 0xff},
+}
+
+// itemEffect is the ring and the label shown in the world where an item was
+// picked up, or where the shield broke.
+type itemEffect struct {
+	world *sim.World
+	kind  sim.ItemKind
+	label string
+	x, y  float64
+	frame int
+}
+
+// updateItems starts the effects of the power-ups picked up or used in the
+// last step. shield is whether the player had a shield before the step.
+func (g *Game) updateItems(shield bool) {
+	w := g.world
+	for _, it := range w.Picked {
+		g.itemEffects = append(g.itemEffects, itemEffect{
+			world: w,
+			kind:  it.Kind,
+			label: itemLabels[it.Kind],
+			x:     float64(it.X + sim.ItemSize/2),
+			y:     float64(it.Y + w.CameraY + sim.ItemSize/2),
+			frame: w.Frame,
+		})
+		g.sound.playSE("item")
+	}
+	if shield && !w.Shield && w.Recover == sim.RecoverFrames {
+		g.itemEffects = append(g.itemEffects, itemEffect{
+			world: w,
+			kind:  sim.ItemShield,
+			label: "ガード!",
+			x:     float64(w.X16/16 + playerWidth/2),
+			y:     float64(w.Y16/16 - w.CameraY + playerHeight/2),
+			frame: w.Frame,
+		})
+		g.sound.playSE("shield")
+	}
+
+	effects := g.itemEffects[:0]
+	for _, e := range g.itemEffects {
+		if e.world.Frame-e.frame < itemEffectDuration {
+			effects = append(effects, e)
+		}
+	}
+	g.itemEffects = effects
+}
+
+func (g *Game) drawItems(screen *ebiten.Image) {
+	op := &ebiten.DrawImageOptions{}
+
+	for _, it := range g.world.Items {
+		y := float64(it.Y + g.world.CameraY)
+		if y < -sim.ItemSize || y > screenHeight {
+			continue
+		}
+
+		// Bobbing on the waves
+		y += 2 * math.Sin(float64(g.counter)/10)
+
+		op.GeoM.Reset()
+		op.GeoM.Translate(float64(it.X), y)
+		screen.DrawImage(TilesImage.SubImage(it.Sprite()).(*ebiten.Image), op)
+	}
+}
+
+// drawPowerUpsOnPlayer draws the shield around the player and the wake of the
+// boost.
+func (g *Game) drawPowerUpsOnPlayer(screen *ebiten.Image) {
+	w := g.world
+	m := g.playerGeoM()
+	x, y := m.Apply(playerWidth/2, playerHeight/2)
+	cx, cy := float32(x), float32(y)
+
+	if w.Boost > 0 {
+		for i := 0; i < 3; i++ {
+			x := cx + float32(i-1)*16
+			l := float32(24 + (g.counter*7+i*13)%24)
+			vector.StrokeLine(screen, x, cy+playerHeight/2, x, cy+playerHeight/2+l, 2, color.RGBA{0xff, 0xff, 0xff, 0xa0}, false)
+		}
+	}
+
+	if w.Shield {
+		clr := itemColors[sim.ItemShield]
+		clr.A = 0xa0
+		r := float32(playerWidth)*0.6 + float32(math.Sin(float64(g.counter)/8))
+		vector.StrokeCircle(screen, cx, cy, r, 2, clr, true)
+	}
+}
+
+func (g *Game) drawItemEffects(screen *ebiten.Image) {
+	for _, e := range g.itemEffects {
+		if e.world != g.world {
+			continue
+		}
+		t := float64(g.world.Frame-e.frame) / itemEffectDuration
+		clr := itemColors[e.kind]
+		clr.A = uint8(0xff * (1 - t))
+		vector.StrokeCircle(screen, float32(e.x), float32(e.y), float32(16+48*t), 3, clr, true)
+
+		op := &text.DrawOptions{}
+		op.GeoM.Translate(e.x, e.y-24-24*t)
+		op.ColorScale.ScaleWithColor(color.White)
+		op.ColorScale.ScaleAlpha(float32(1 - t))
+		op.LineSpacing = hudFontSize
+		op.PrimaryAlign = text.AlignCenter
+		text.Draw(
+			screen,
+			e.label,
+			&text.GoTextFace{
+				Source: misakiFont,
+				Size:   hudFontSize,
+			},
+			op,
+		)
+	}
+}
+
+// drawPowerUps draws the icons of the power-ups the player has, with the
+// time left of the ones that run out.
+func (g *Game) drawPowerUps(screen *ebiten.Image) {
+	w := g.world
+	x := float64(powerUpX)
+	for _, p := range []struct {
+		kind   sim.ItemKind
+		active bool
+		left   int
+		total  int
+	}{
+		{sim.ItemShield, w.Shield, 0, 0},
+		{sim.ItemCalm, w.Calm > 0, w.Calm, sim.CalmFrames},
+		{sim.ItemBoost, w.Boost > 0, w.Boost, sim.BoostFrames},
+	} {
+		if !p.active {
+			continue
+		}
+		it := sim.Item{Kind: p.kind}
+		op := &ebiten.DrawImageOptions{}
+		op.GeoM.Scale(powerUpIcon/float64(sim.ItemSize), powerUpIcon/float64(sim.ItemSize))
+		op.GeoM.Translate(x, powerUpY)
+		// Blinking before running out
+		if p.total > 0 && p.left < 60 && p.left/8%2 == 0 {
+			op.ColorScale.ScaleAlpha(0.3)
+		}
+		screen.DrawImage(TilesImage.SubImage(it.Sprite()).(*ebiten.Image), op)
+		x += powerUpIcon + 4
+
+		if p.total == 0 {
+			x += 8
+			continue
+		}
+		r := float32(p.left) / float32(p.total)
+		vector.DrawFilledRect(screen, float32(x), powerUpY+powerUpIcon/2-3, powerUpBar, 6, color.RGBA{0, 0, 0, 96}, false)
+		vector.DrawFilledRect(screen, float32(x), powerUpY+powerUpIcon/2-3, powerUpBar*r, 6, itemColors[p.kind], false)
+
+		textOp := &text.DrawOptions{}
+		textOp.GeoM.Translate(x+powerUpBar+4, powerUpY+powerUpIcon/2)
+		textOp.ColorScale.ScaleWithColor(color.White)
+		textOp.LineSpacing = hudFontSize
+		textOp.SecondaryAlign = text.AlignCenter
+		text.Draw(
+			screen,
+			fmt.Sprintf("%d", (p.left+59)/60),
+			&text.GoTextFace{
+				Source: misakiFont,
+				Size:   hudFontSize,
+			},
+			textOp,
+		)
+		x += powerUpBar + 4 + hudFontSize + 8
+	}
+}
@@ -0,0 +1,79 @@
+// Package leaderboard is the API of the score server: the scores the game
+// submits with the replays of the runs, and their signatures.
+//
+// A client POSTs a Submission as JSON to /scores, with the HMAC-SHA256 of the
+// body by the shared key in the SignatureHeader when the server has a key.
+// GET /scores returns the top Entries.
+//
+// The key ships inside every client, so anyone who has the game can sign
+// whatever they like with it. It only keeps out casual submissions, e.g. on a
+// closed LAN, and is no protection against forged scores. What keeps them out
+// is the server playing the replay back.
+package leaderboard
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/shuuuta/shimanuke-chuta/sim"
+)
+
+// SignatureHeader is the header of the signature of a submission in hex.
+const SignatureHeader = "X-Signature"
+
+// Submission is the result of a run sent to the server.
+type Submission struct {
+	Name     string `json:"name"`
+	Distance int    `json:"distance"`
+	Island   string `json:"island"`
+	// Frame is the frame the run ended at.
+	Frame      int            `json:"frame"`
+	Seed       uint64         `json:"seed"`
+	Difficulty sim.Difficulty `json:"difficulty"`
+	Endless    bool           `json:"endless,omitempty"`
+	// Replay is the binary replay of the run, and ReplayHash is HashReplay
+	// of it.
+	Replay     []byte `json:"replay"`
+	ReplayHash string `json:"replayHash"`
+}
+
+// Entry is a score accepted by the server.
+type Entry struct {
+	ID         int            `json:"id"`
+	Name       string         `json:"name"`
+	Distance   int            `json:"distance"`
+	Island     string         `json:"island"`
+	Seed       uint64         `json:"seed"`
+	Difficulty sim.Difficulty `json:"difficulty"`
+	Endless    bool           `json:"endless,omitempty"`
+	// Frames is how long the run lasted.
+	Frames     int       `json:"frames"`
+	ReplayHash string    `json:"replayHash"`
+	Time       time.Time `json:"time"`
+}
+
+// HashReplay returns the SHA-256 of the replay in hex.
+func HashReplay(replay []byte) string {
+	h := sha256.Sum256(replay)
+	return hex.EncodeToString(h[:])
+}
+
+// Sign returns the signature of the body with the key in hex.
+func Sign(key, body []byte) string {
+	m := hmac.New(sha256.New, key)
+	m.Write(body)
+	return hex.EncodeToString(m.Sum(nil))
+}
+
+// Verify reports whether the signature is the one of the body with the key.
+func Verify(key, body []byte, signature string) bool {
+	s, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	m := hmac.New(sha256.New, key)
+	m.Write(body)
+	return hmac.Equal(s, m.Sum(nil))
+}
@@ -3,12 +3,17 @@ package main
 import (
 	"bytes"
 	_ "embed"
+	"flag"
 	"fmt"
 	"image"
 	"image/color"
 	"log"
 	"math"
 	"math/rand/v2"
+	"time"
+
+	"github.com/shuuuta/shimanuke-chuta/resources"
+	"github.com/shuuuta/shimanuke-chuta/sim"
 
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
@@ -16,56 +21,42 @@ import (
 	"github.com/hajimehoshi/ebiten/v2/text/v2"
 )
 
-func getTravelDistance(y16 int) int {
-	return (y16 - playerPositionY) / 16 * 20
-}
-func pxToTravelDistance(y int) int {
-	return y * 20
-}
-
 var (
 	muteki = false
 	dev    = false
 )
 
 const (
-	screenWidth  = 480.0
-	screenHeight = 640.0
-
-	waveAreaWidth  = screenWidth
-	waveAreaHeight = screenHeight
+	screenWidth  = sim.ScreenWidth
+	screenHeight = sim.ScreenHeight
 
-	tileSize = 32
+	waveAreaWidth  = sim.WaveAreaWidth
+	waveAreaHeight = sim.WaveAreaHeight
 
-	playerWidth     = 64
-	playerHeight    = 64
-	playerPositionY = (screenHeight - playerHeight - tileSize*4) * 16
+	tileSize = sim.TileSize
 
-	surfWidth  = 64
-	surfHeight = 64
+	playerWidth  = sim.PlayerWidth
+	playerHeight = sim.PlayerHeight
 
-	surfStartOffset = 48
+	surfWidth  = sim.SurfWidth
+	surfHeight = sim.SurfHeight
 )
 
 var (
-	//go:embed resources/tiles.png
-	Tiles_png  []byte
 	TilesImage *ebiten.Image
 
-	//go:embed resources/player.png
-	Player_png  []byte
 	PlayerImage *ebiten.Image
 	PlayerRow   int
 )
 
 func init() {
-	timg, _, err := image.Decode(bytes.NewReader(Tiles_png))
+	timg, _, err := image.Decode(bytes.NewReader(resources.TilesPNG))
 	if err != nil {
 		log.Fatal(err)
 	}
 	TilesImage = ebiten.NewImageFromImage(timg)
 
-	pimg, _, err := image.Decode(bytes.NewReader(Player_png))
+	pimg, _, err := image.Decode(bytes.NewReader(resources.PlayerPNG))
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -103,354 +94,343 @@ func init() {
 	k8x12sFont = k
 }
 
-type waveType int
-
-const (
-	waveToLeft waveType = iota
-	waveToRight
-)
-
-type waveArea = struct {
-	Y        int
-	WaveType waveType
-}
-
-type Stage struct {
-	name         string
-	dist         int
-	speed        int
-	surfGap      int
-	surfInterval int
-}
-
-type Stages []Stage
-
 type Mode int
 
 const (
 	ModeStartMenu Mode = iota
 	ModeGame
+	ModePause
 	ModeGameOver
+	ModeSettings
+	ModeGoal
+	ModeStageSelect
+	ModeOnlineLobby
+)
+
+// runKind is how the current run was started.
+type runKind int
+
+const (
+	runNormal runKind = iota
+	runDaily
+	runEndless
+	runPractice
+	runDemo
+	runRace
+	runOnline
 )
 
 type Game struct {
-	counter  int
-	mode     Mode
-	stages   Stages
-	location string
+	counter int
+	mode    Mode
 
-	speed int
+	run        runKind
+	dailyDate  string
+	difficulty sim.Difficulty
 
 	// Input
-	touchIDs []ebiten.TouchID
-
-	// Counter
-	countAfterClick int
+	touchIDs   []ebiten.TouchID
+	gamepadIDs []ebiten.GamepadID
+	bindings   bindings
+	controller sim.Controller
+	idle       int
+
+	// Menus
+	menuCursor     int
+	settingsCursor int
+	stageCursor    int
+	waitingBinding bool
 
 	// Camera
 	cameraX int
-	cameraY int
 
-	// The player's position
-	x16  int
-	y16  int
-	vx16 int
+	world *sim.World
+
+	// Race on the same device
+	race        *sim.Race
+	raceScreens [racePlayers]*ebiten.Image
 
-	shipDir int
+	// Online race. relayURL is empty when there is no relay to race on.
+	relayURL   string
+	playerName string
+	online     *onlineRace
 
-	//waves
-	waveAreas    []*waveArea
-	surfs        []*surf
-	surfInterval int
-	surfGap      int
+	// Leaderboard. scoreServer is empty when the scores are not submitted.
+	scoreServer string
+	scoreKey    string
+	score       *scoreSubmission
+
+	// Replay
+	recording     *sim.Replay
+	replay        *sim.Replay
+	replayMessage string
+
+	// Arrival banners
+	banners []banner
+
+	// Power-ups
+	itemEffects []itemEffect
+
+	// Records
+	records   records
+	newRecord bool
+
+	// Ghosts of the best runs. ghost is the one raced against in the current
+	// run, and ghostRecording is the current run to be the next one.
+	ghostRuns      ghostRuns
+	ghost          *ghostRun
+	ghostRecording *ghostRun
+
+	sound *sound
 }
 
 func (g *Game) Layout(outsideWidth, outsideHeight int) (int, int) {
+	if g.run == runRace {
+		return screenWidth * racePlayers, screenHeight
+	}
 	return screenWidth, screenHeight
 }
 
-func (g *Game) isSelectJustPressed() bool {
-	if inpututil.IsKeyJustPressed(ebiten.KeySpace) {
-		return true
-	}
-	if inpututil.IsMouseButtonJustReleased(ebiten.MouseButtonLeft) {
-		return true
-	}
-	if len(g.touchIDs) > 0 {
-		return true
-	}
-	return false
+// newSeed returns a fresh seed for a run that is not meant to be reproduced.
+func newSeed() uint64 {
+	return rand.Uint64()
 }
 
-func (g *Game) isRightJustPressed() bool {
-	if inpututil.IsKeyJustPressed(ebiten.KeyArrowRight) {
-		return true
-	}
-	x, _ := ebiten.CursorPosition()
-	if inpututil.IsMouseButtonJustReleased(ebiten.MouseButtonLeft) && x >= screenWidth/2 {
-		return true
-	}
-	if len(g.touchIDs) > 0 {
-		x, _ := ebiten.TouchPosition(g.touchIDs[0])
-		if x >= screenWidth/2 {
-			return true
-		}
-	}
-	return false
+// init resets the game for a new run. The same config always produces the
+// same waves and surfs for the same inputs.
+func (g *Game) init(c sim.Config) {
+	g.counter = 0
+	g.cameraX = 0
+	g.run = runNormal
+	g.dailyDate = ""
+	g.world = sim.New(c)
+	g.world.Invincible = muteki
+	if g.race != nil {
+		ebiten.SetWindowSize(screenWidth, screenHeight)
+		g.race = nil
+	}
+	if g.online != nil {
+		g.online.conn.close()
+		g.online = nil
+	}
+	g.controller = deviceController{g}
+	g.idle = 0
+
+	g.recording = &sim.Replay{
+		Route:      sim.RouteHash(),
+		Seed:       c.Seed,
+		Difficulty: c.Difficulty,
+		Endless:    c.Endless,
+		StartStage: c.StartStage,
+	}
+	g.replay = nil
+	g.replayMessage = ""
+	g.score = nil
+
+	g.newRecord = false
+	g.ghost = nil
+	g.ghostRecording = newGhostRun(g.world)
+	g.banners = nil
+	g.itemEffects = nil
 }
 
-func (g *Game) isLeftJustPressed() bool {
-	if inpututil.IsKeyJustPressed(ebiten.KeyArrowLeft) {
-		return true
-	}
-	x, _ := ebiten.CursorPosition()
-	if inpututil.IsMouseButtonJustReleased(ebiten.MouseButtonLeft) && x < screenWidth/2 {
-		return true
+func NewGame() *Game {
+	g := &Game{
+		difficulty: loadDifficulty(),
+		records:    loadRecords(),
+		ghostRuns:  loadGhostRuns(),
+		sound:      newSound(),
+		bindings:   loadBindings(),
 	}
-	if len(g.touchIDs) > 0 {
-		x, _ := ebiten.TouchPosition(g.touchIDs[0])
-		if x < screenWidth/2 {
-			return true
-		}
+	g.init(g.config(newSeed()))
+	if err := g.sound.validate(g.world.Stages); err != nil {
+		log.Fatal(err)
 	}
-	return false
+	return g
 }
 
-func (g *Game) init() {
-	g.counter = 0
-	g.x16 = (screenWidth/2 - playerWidth/2) * 16
-	g.y16 = playerPositionY
-	g.cameraX = 0
-	g.cameraY = 0
-
-	//init Stage
-	g.stages = Stages{
-		Stage{
-			name:         "八丈島",
-			dist:         0,
-			speed:        2,
-			surfGap:      9,
-			surfInterval: 12,
-		}, Stage{
-			name:         "御蔵島",
-			dist:         83,
-			speed:        2,
-			surfGap:      8,
-			surfInterval: 12,
-		}, Stage{
-			name:         "三宅島",
-			dist:         106,
-			speed:        3,
-			surfGap:      8,
-			surfInterval: 12,
-		}, Stage{
-			name:         "神津島",
-			dist:         133,
-			speed:        3,
-			surfGap:      8,
-			surfInterval: 11,
-		}, Stage{
-			name:         "式根島",
-			dist:         143,
-			speed:        4,
-			surfGap:      8,
-			surfInterval: 11,
-		}, Stage{
-			name:         "新島",
-			dist:         150,
-			speed:        4,
-			surfGap:      8,
-			surfInterval: 10,
-		}, Stage{
-			name:         "利島",
-			dist:         160,
-			speed:        5,
-			surfGap:      8,
-			surfInterval: 10,
-		}, Stage{
-			name:         "大島",
-			dist:         176,
-			speed:        5,
-			surfGap:      7,
-			surfInterval: 10,
-			//}, Stage{
-			//	name:         "千葉",
-			//	dist:         197,
-			//	speed:        5,
-			//	surfGap:      7,
-			//	surfInterval: 9,
-			//}, Stage{
-			//	name:         "神奈川",
-			//	dist:         225,
-			//	speed:        5,
-			//	surfGap:      7,
-			//	surfInterval: 8,
-		}, Stage{
-			name:         "東京",
-			dist:         280,
-			speed:        6,
-			surfGap:      7,
-			surfInterval: 8,
-		},
+func (g *Game) Update() error {
+	// Everything including the animations stands still while paused.
+	if g.mode != ModePause {
+		g.counter++
 	}
-	g.setStage()
-
-	//init waves
-	g.waveAreas = []*waveArea{}
-	for i := 0; i < 3; i++ {
-		t := waveToLeft
-		if i%2 == 0 {
-			t = waveToRight
-		}
-		g.waveAreas = append(g.waveAreas, &waveArea{
-			Y:        -waveAreaHeight * i,
-			WaveType: t,
-		})
-	}
-	//init surfs
-	g.surfs = []*surf{}
-
-	initSurfsNum := int(math.Floor(float64(screenHeight / ((g.surfGap + 1) * surfHeight))))
-	if initSurfsNum < 0 {
-		initSurfsNum = 1
-	}
-	for i := 0; i < initSurfsNum; i++ {
-		s := g.stages[0]
-		y := -surfStartOffset * tileSize
-
-		if i > 0 {
-			lastY := g.surfs[len(g.surfs)-1].Y
-			for _, v := range g.stages {
-				if v.dist*1000 < pxToTravelDistance(-lastY) {
-					s = v
-				}
-			}
-			y = lastY - surfHeight - s.surfGap*tileSize
-		}
+	g.updateInput()
+	defer g.updateBGM()
 
-		g.surfs = append(g.surfs, &surf{
-			Y:         y,
-			LeftWidth: genSurfLeftWidth(s.surfGap),
-			Gap:       s.surfGap,
-		})
+	if g.updateSoundSettings() {
+		return nil
 	}
-}
 
-func NewGame() ebiten.Game {
-	g := &Game{}
-	g.init()
-	return g
-}
-
-func (g *Game) Update() error {
-	g.counter++
-	g.touchIDs = inpututil.AppendJustPressedTouchIDs(g.touchIDs[:0])
+	if dev && inpututil.IsKeyJustPressed(ebiten.KeyH) {
+		showHitbox = !showHitbox
+	}
 
 	switch g.mode {
 	case ModeStartMenu:
-		if g.isSelectJustPressed() {
-			g.mode = ModeGame
-		}
-	case ModeGame:
-		g.setStage()
-
-		g.countAfterClick += 1
-		g.cameraY += g.speed
-		g.y16 += g.speed * 16
-
-		if g.isRightJustPressed() {
-			g.shipDir = 1
-			g.countAfterClick = 0
-			g.vx16 = 96
+		if r, err := droppedReplay(); err != nil {
+			log.Printf("Failed to load the dropped replay: %v", err)
+		} else if r != nil {
+			g.startReplay(r)
+			break
 		}
-		if g.isLeftJustPressed() {
-			g.shipDir = 2
-			g.countAfterClick = 0
-			g.vx16 = -96
+		if g.updateIdle() {
+			break
 		}
+		g.updateStartMenu()
 
-		g.x16 += g.vx16
-		//Check is player moves off screen
-		if g.x16 < 0 {
-			g.x16 = 0
+	case ModeGame:
+		if g.run == runRace {
+			g.updateRace()
+			break
 		}
-		if g.x16 > (screenWidth-playerWidth)*16 {
-			g.x16 = (screenWidth - playerWidth) * 16
+		if g.run == runOnline {
+			g.updateOnlineGame()
+			break
 		}
-
-		g.vx16 += g.getWaveDirection()
-
-		if g.vx16 > 96 {
-			g.vx16 = 96
+		if g.run == runDemo && g.isAnyJustPressed() {
+			g.quit()
+			break
 		}
-		if g.vx16 < -96 {
-			g.vx16 = -96
+		if g.isPauseJustPressed() {
+			g.mode = ModePause
+			break
 		}
-
-		//Add wave
-		if g.cameraY%screenHeight < g.speed {
-			t := waveToLeft
-			if rand.IntN(2)%2 == 0 {
-				t = waveToRight
-			}
-			g.waveAreas = append(g.waveAreas, &waveArea{
-				Y:        g.waveAreas[len(g.waveAreas)-1].Y - screenHeight,
-				WaveType: t,
-			})
-			g.waveAreas = g.waveAreas[1:]
+		in, ok := g.input()
+		if !ok {
+			// The replay has ended before the run was over.
+			g.gameOver()
+			break
 		}
-
-		//Add surfs
-		if g.cameraY%((g.surfInterval+1)*tileSize) < g.speed {
-			lastY := g.surfs[len(g.surfs)-1].Y
-			s := g.stages[0]
-			for _, v := range g.stages {
-				if v.dist*1000 < pxToTravelDistance(-lastY) {
-					s = v
-				}
-			}
-			g.surfs = append(g.surfs, &surf{
-				Y:         lastY - surfHeight - s.surfInterval*tileSize,
-				LeftWidth: genSurfLeftWidth(s.surfGap),
-				Gap:       s.surfGap,
-			})
-
-			rmCount := 0
-			for _, s := range g.surfs {
-				if s.Y+g.cameraY > screenHeight {
-					rmCount++
-				}
-			}
-			g.surfs = g.surfs[rmCount:]
+		g.recording.Record(in)
+		g.step(in)
+		g.ghostRecording.record(g.world)
+		if g.world.Over {
+			g.gameOver()
 		}
-
-		if g.hit() && !muteki {
-			g.counter = 0
-			g.mode = ModeGameOver
+		if g.world.Goal {
+			g.goal()
 		}
 
+	case ModePause:
+		g.updatePause()
+
 	case ModeGameOver:
 		g.updateGameOver()
+
+	case ModeSettings:
+		g.updateSettings()
+
+	case ModeGoal:
+		g.updateGoal()
+
+	case ModeStageSelect:
+		g.updateStageSelect()
+
+	case ModeOnlineLobby:
+		g.updateOnlineLobby()
 	}
 	return nil
 }
 
-func (g *Game) setStage() {
-	var s Stage
-	for _, v := range g.stages {
-		if v.dist*1000 <= getTravelDistance(g.y16) {
-			s = v
+// step advances the world by a frame with the effects and the sounds of what
+// has happened in it.
+func (g *Game) step(in sim.Input) {
+	location := g.world.Location
+	shield := g.world.Shield
+	g.world.Step(in)
+
+	if in.Left || in.Right {
+		g.sound.playSE("paddle")
+	}
+	g.updateItems(shield)
+	if g.world.Location != location {
+		g.startBanner()
+		g.sound.playSE("arrive")
+	}
+	if g.world.Over {
+		g.sound.playSE("hit")
+	}
+}
+
+// startDaily starts the daily challenge of today.
+func (g *Game) startDaily() {
+	date := sim.DailyDate(time.Now())
+	g.init(g.config(sim.DailySeed(date)))
+	g.run = runDaily
+	g.dailyDate = date
+	if r, ok := g.ghostRuns[g.recordMode()]; ok && r.Seed == g.world.Seed {
+		g.ghost = r
+	}
+	g.mode = ModeGame
+}
+
+// startEndless starts a run that goes on past 東京.
+func (g *Game) startEndless() {
+	c := g.config(newSeed())
+	c.Endless = true
+	g.init(c)
+	g.run = runEndless
+	g.mode = ModeGame
+}
+
+// recordMode returns the mode that the current run is recorded as.
+func (g *Game) recordMode() string {
+	switch g.run {
+	case runDaily:
+		return dailyRecordMode(g.dailyDate, g.world.Difficulty)
+	case runEndless:
+		return endlessRecordMode(g.world.Difficulty)
+	}
+	return normalRecordMode(g.world.Difficulty)
+}
+
+func (g *Game) gameOver() {
+	g.counter = 0
+	g.mode = ModeGameOver
+	g.updateRecords()
+}
+
+// goal starts the victory sequence of the run that has reached 東京.
+func (g *Game) goal() {
+	g.counter = 0
+	g.mode = ModeGoal
+	g.updateRecords()
+}
+
+func (g *Game) updateRecords() {
+	// Replays and demos are someone else's runs, and practice runs start
+	// halfway.
+	if g.replay == nil && g.run != runPractice && g.run != runDemo {
+		if g.run == runDaily {
+			g.records.pruneDaily(g.dailyDate)
+			g.ghostRuns.pruneDaily(g.dailyDate)
+		}
+		g.newRecord = g.records.update(g.recordMode(), g.world)
+		if mode := g.ghostMode(); mode != "" {
+			g.ghostRuns.update(mode, g.ghostRecording)
+		}
+		if g.scoreServer != "" {
+			g.submitScore()
 		}
 	}
-	g.speed = s.speed
-	g.surfInterval = s.surfInterval
-	g.surfGap = s.surfGap
-	g.location = s.name
 }
 
 func (g *Game) Draw(screen *ebiten.Image) {
+	if g.run == runRace {
+		g.drawRace(screen)
+		return
+	}
+
 	g.drawWaves(screen)
 	g.drawSurfs(screen)
+	g.drawObstacles(screen)
+	g.drawItems(screen)
+	if g.online != nil && g.online.ghosts != nil {
+		g.drawGhosts(screen)
+	}
+	if g.ghost != nil && (g.mode == ModeGame || g.mode == ModePause) {
+		g.drawGhost(screen)
+	}
 
 	if g.mode == ModeStartMenu {
 		g.drawStartMenu(screen)
@@ -459,11 +439,49 @@ func (g *Game) Draw(screen *ebiten.Image) {
 	if g.mode == ModeGame {
 		g.drawGameScreen(screen)
 		g.drawPlayer(screen)
+		switch g.run {
+		case runDemo:
+			g.drawDemo(screen)
+		case runOnline:
+			// Online races can't be paused.
+		default:
+			g.drawPauseButton(screen)
+		}
+	}
+
+	if g.mode == ModePause {
+		g.drawGameScreen(screen)
+		g.drawPlayer(screen)
+		g.drawPause(screen)
 	}
 
 	if g.mode == ModeGameOver {
 		g.drawPlayer(screen)
-		g.drawGameOver(screen)
+		if g.run == runOnline {
+			g.drawOnlineResult(screen)
+		} else {
+			g.drawGameOver(screen)
+		}
+	}
+
+	if g.mode == ModeSettings {
+		g.drawSettings(screen)
+	}
+
+	if g.mode == ModeGoal {
+		g.drawGoal(screen)
+	}
+
+	if g.mode == ModeStageSelect {
+		g.drawStageSelect(screen)
+	}
+
+	if g.mode == ModeOnlineLobby {
+		g.drawOnlineLobby(screen)
+	}
+
+	if dev && showHitbox {
+		g.drawHitbox(screen)
 	}
 
 	if dev {
@@ -472,78 +490,51 @@ func (g *Game) Draw(screen *ebiten.Image) {
 				"Hit: %v, "+
 					"Y:%v, vx: %v\n"+
 					"dist: %v, "+
-					"waves: %v, surfs: %v",
-				g.hit(),
-				g.cameraY,
-				g.vx16,
-				fmt.Sprintf("%.2fkm", float64(getTravelDistance(g.y16)/1000)),
-				len(g.waveAreas),
-				len(g.surfs),
+					"waves: %v, surfs: %v\n"+
+					"seed: %v, %v",
+				g.world.Hit(),
+				g.world.CameraY,
+				g.world.VX16,
+				fmt.Sprintf("%.2fkm", float64(g.world.Distance()/1000)),
+				len(g.world.WaveAreas),
+				len(g.world.Surfs),
+				g.world.Seed,
+				g.world.Difficulty,
 			),
 		)
 		ebitenutil.DebugPrint(screen, fmt.Sprintf("TPS: %0.1f", ebiten.ActualTPS()))
 	}
 }
 
-func (g *Game) hit() bool {
-	if g.mode != ModeGame {
-		return false
-	}
-
-	x0 := int(math.Floor(float64(g.x16 / 16)))
-	x1 := x0 + playerWidth
-	y0 := int(math.Floor(float64(g.y16/16))) - g.cameraY
-	y1 := y0 + playerHeight
-
-	//out of screen
-	if x0 <= 0 {
-		return true
-	}
-	if x1 >= screenWidth {
-		return true
-	}
-
-	//hit surf
-	for _, s := range g.surfs {
-		sy0 := s.Y + g.cameraY
-		sy1 := sy0 + surfHeight
-
-		rx0 := 0
-		rx1 := s.LeftWidth * tileSize
-		lx0 := rx1 + s.Gap*tileSize
-		lx1 := screenWidth
-
-		if y0 < sy1 && sy0 < y1 {
-			if x0 < rx1 && rx0 < x1 {
-				return true
-			}
-			if x0 < int(lx1) && lx0 < x1 {
-				return true
-			}
-		}
-	}
-	return false
+// playerGeoM returns the transformation to draw the player's sprite.
+func (g *Game) playerGeoM() ebiten.GeoM {
+	w := g.world
+	var m ebiten.GeoM
+	m.Translate(-float64(playerWidth)/2.0, -float64(playerHeight)/2.0)
+	m.Rotate(w.PlayerAngle())
+	m.Translate(float64(playerWidth)/2.0, float64(playerHeight)/2.0)
+	m.Translate(float64(w.X16/16)-float64(g.cameraX), float64(w.Y16/16)-float64(w.CameraY))
+	if g.mode == ModeGoal {
+		m.Translate(0, -g.goalSailOffset())
+	}
+	return m
 }
 
 func (g *Game) drawPlayer(screen *ebiten.Image) {
 	op := &ebiten.DrawImageOptions{}
 
-	px0 := 0
-	py0 := 0
-	if g.countAfterClick < 30 {
-		px0 = int(math.Floor(float64(g.cameraY/g.speed/5%4))) * playerWidth
-		py0 = g.shipDir * playerHeight
-	} else {
-		px0 = int(math.Floor(float64(g.cameraY/g.speed/10%4))) * playerWidth
-	}
+	col, row := g.world.PlayerFrame()
+	px0 := col * playerWidth
+	py0 := row * playerHeight
 
-	op.GeoM.Translate(-float64(playerWidth)/2.0, -float64(playerHeight)/2.0)
-	op.GeoM.Rotate(float64(g.vx16) / 96.0 * math.Pi / 6)
-	op.GeoM.Translate(float64(playerWidth)/2.0, float64(playerHeight)/2.0)
-	op.GeoM.Translate(float64(g.x16/16)-float64(g.cameraX), float64(g.y16/16)-float64(g.cameraY))
+	op.GeoM = g.playerGeoM()
 	op.Filter = ebiten.FilterLinear
 
-	screen.DrawImage(PlayerImage.SubImage(image.Rect(px0, py0, px0+playerWidth, py0+playerHeight)).(*ebiten.Image), op)
+	// Blinking while recovering from a hit the shield has absorbed
+	if g.world.Recover == 0 || g.counter/4%2 == 0 {
+		screen.DrawImage(PlayerImage.SubImage(image.Rect(px0, py0, px0+playerWidth, py0+playerHeight)).(*ebiten.Image), op)
+	}
+	g.drawPowerUpsOnPlayer(screen)
 }
 
 func (g *Game) drawGameScreen(screen *ebiten.Image) {
@@ -554,13 +545,18 @@ func (g *Game) drawGameScreen(screen *ebiten.Image) {
 	op.PrimaryAlign = text.AlignCenter
 	text.Draw(
 		screen,
-		fmt.Sprintf("%.1fkm", float64(getTravelDistance(g.y16))/1000),
+		fmt.Sprintf("%.1fkm", float64(g.world.Distance())/1000),
 		&text.GoTextFace{
 			Source: misakiFont,
 			Size:   fontSize,
 		},
 		op,
 	)
+
+	g.drawProgress(screen)
+	g.drawPowerUps(screen)
+	g.drawItemEffects(screen)
+	g.drawBanner(screen)
 }
 
 func (g *Game) drawWaves(screen *ebiten.Image) {
@@ -570,8 +566,8 @@ func (g *Game) drawWaves(screen *ebiten.Image) {
 	const waveX2 = waveWidth
 
 	op := &ebiten.DrawImageOptions{}
-	for _, w := range g.waveAreas {
-		areaY := w.Y + g.cameraY
+	for _, w := range g.world.WaveAreas {
+		areaY := w.Y + g.world.CameraY
 		if areaY < -waveAreaHeight || areaY > screenHeight {
 			continue
 		}
@@ -585,7 +581,7 @@ func (g *Game) drawWaves(screen *ebiten.Image) {
 				x := waveX1
 				y := 0
 
-				if w.WaveType == waveToRight {
+				if w.WaveType == sim.WaveToRight {
 					x = waveX2
 				}
 
@@ -600,36 +596,7 @@ func (g *Game) drawWaves(screen *ebiten.Image) {
 	}
 }
 
-func (g *Game) getWaveDirection() int {
-	y := g.y16/16 - g.cameraY
-	for _, w := range g.waveAreas {
-		wy0 := w.Y + g.cameraY
-		wy1 := w.Y + g.cameraY + waveAreaHeight
-		if y > wy0 && y <= wy1 {
-			switch w.WaveType {
-			case waveToLeft:
-				return -4
-			case waveToRight:
-				return 4
-			default:
-				return 0
-			}
-		}
-	}
-	return 0
-}
-
-type surf = struct {
-	Y         int
-	LeftWidth int
-	Gap       int
-}
-
 func (g *Game) drawSurfs(screen *ebiten.Image) {
-	const surfX1 = 128
-	const surfX2 = 160
-	const surfX3 = 224
-
 	op := &ebiten.DrawImageOptions{}
 
 	sy := 0
@@ -637,43 +604,40 @@ func (g *Game) drawSurfs(screen *ebiten.Image) {
 		sy = surfHeight
 	}
 
-	for _, s := range g.surfs {
-		y := float64(s.Y + g.cameraY)
+	for _, s := range g.world.Surfs {
+		y := float64(s.Y + g.world.CameraY)
 		if y < -surfHeight || y > screenHeight {
 			continue
 		}
 
-		for i := s.LeftWidth; i >= -1; i = i - 2 {
-			sx := surfX2
-			if i == s.LeftWidth {
-				sx = surfX3
-			}
-			op.GeoM.Reset()
-			op.GeoM.Translate(float64(i*tileSize), y)
-			screen.DrawImage(TilesImage.SubImage(image.Rect(sx, sy, sx+surfWidth, sy+surfHeight)).(*ebiten.Image), op)
-		}
-
-		for i := 0; i < screenWidth/tileSize-s.LeftWidth-s.Gap; i++ {
-			sx := surfX2
-			if i == 0 {
-				sx = surfX1
-			}
+		for _, t := range s.Tiles() {
 			op.GeoM.Reset()
-			op.GeoM.Translate(float64(i*tileSize+s.LeftWidth*tileSize+s.Gap*tileSize), y)
-			screen.DrawImage(TilesImage.SubImage(image.Rect(sx, sy, sx+surfWidth, sy+surfHeight)).(*ebiten.Image), op)
+			op.GeoM.Translate(float64(t.X), y)
+			screen.DrawImage(TilesImage.SubImage(image.Rect(t.SX, sy, t.SX+surfWidth, sy+surfHeight)).(*ebiten.Image), op)
 		}
 	}
 }
 
-func genSurfLeftWidth(surfGap int) int {
-	maxLeftWidth := screenWidth/tileSize - surfGap - 1
-	return rand.IntN(maxLeftWidth) + 1
+func (g *Game) drawObstacles(screen *ebiten.Image) {
+	op := &ebiten.DrawImageOptions{}
+
+	for _, o := range g.world.Obstacles {
+		y := float64(o.Y + g.world.CameraY)
+		_, h := o.Size()
+		if y < -float64(h) || y > screenHeight {
+			continue
+		}
+
+		op.GeoM.Reset()
+		op.GeoM.Translate(float64(o.X16/16), y)
+		screen.DrawImage(TilesImage.SubImage(o.Sprite(g.counter/20)).(*ebiten.Image), op)
+	}
 }
 
 func sampleLog(screen *ebiten.Image, message string) {
 	const (
 		mWidth  = 128 * 2
-		row     = 2
+		row     = 3
 		mHeight = 16 * row
 		marginB = 8 * row
 		mRight  = 8
@@ -688,9 +652,31 @@ func sampleLog(screen *ebiten.Image, message string) {
 }
 
 func main() {
+	replayFile := flag.String("replay", "", "Play back a replay file")
+	relayURL := flag.String("relay", flagDefault("relay"), "URL of the relay server for the online race, e.g. ws://localhost:8081/")
+	playerName := flag.String("name", flagDefault("name"), "Your name in the online race and the leaderboard")
+	scoreServer := flag.String("scores", flagDefault("scores"), "URL of the score server to submit the scores to, e.g. http://localhost:8082/")
+	scoreKey := flag.String("score-key", flagDefault("score-key"), "Shared key to sign the scores with, which only keeps out casual submissions")
+	flag.Parse()
+
+	g := NewGame()
+	g.relayURL = *relayURL
+	g.playerName = *playerName
+	g.scoreServer = *scoreServer
+	g.scoreKey = *scoreKey
+	if *replayFile != "" {
+		r, err := loadReplayFile(*replayFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		g.startReplay(r)
+	}
+
 	ebiten.SetWindowSize(screenWidth, screenHeight)
 	ebiten.SetWindowTitle("島抜けチュータ")
-	if err := ebiten.RunGame(NewGame()); err != nil {
+	// Keep updating while unfocused so that a run is paused instead of frozen.
+	ebiten.SetRunnableOnUnfocused(true)
+	if err := ebiten.RunGame(g); err != nil {
 		log.Fatal(err)
 	}
 }
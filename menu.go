@@ -0,0 +1,74 @@
+package main
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/text/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+const (
+	menuItemWidth  = 400
+	menuItemHeight = 40
+)
+
+// menuItemRect returns the area of the i-th item of a menu starting at top.
+func menuItemRect(top, i int) image.Rectangle {
+	x0 := screenWidth/2 - menuItemWidth/2
+	y0 := top + i*menuItemHeight
+	return image.Rect(x0, y0, x0+menuItemWidth, y0+menuItemHeight)
+}
+
+// updateMenu moves the cursor of a menu with n items, and returns the index
+// of the item chosen in this frame, or -1.
+func (g *Game) updateMenu(cursor *int, n, top int) int {
+	if g.isActionJustPressed(actionUp) {
+		*cursor = (*cursor + n - 1) % n
+	}
+	if g.isActionJustPressed(actionDown) {
+		*cursor = (*cursor + 1) % n
+	}
+	if g.isActionJustPressed(actionSelect) {
+		return *cursor
+	}
+
+	p, ok := g.justPressedPosition()
+	if !ok {
+		return -1
+	}
+	for i := 0; i < n; i++ {
+		if p.In(menuItemRect(top, i)) {
+			*cursor = i
+			return i
+		}
+	}
+	return -1
+}
+
+func drawMenu(screen *ebiten.Image, labels []string, cursor, top int) {
+	for i, l := range labels {
+		r := menuItemRect(top, i)
+		if i == cursor {
+			vector.DrawFilledRect(screen, float32(r.Min.X), float32(r.Min.Y), float32(r.Dx()), float32(r.Dy()), color.RGBA{0, 0, 0, 96}, false)
+			vector.StrokeRect(screen, float32(r.Min.X), float32(r.Min.Y), float32(r.Dx()), float32(r.Dy()), 2, color.White, false)
+		}
+
+		op := &text.DrawOptions{}
+		op.GeoM.Translate(float64(r.Min.X+r.Dx()/2), float64(r.Min.Y+r.Dy()/2))
+		op.ColorScale.ScaleWithColor(color.White)
+		op.LineSpacing = fontSize
+		op.PrimaryAlign = text.AlignCenter
+		op.SecondaryAlign = text.AlignCenter
+		text.Draw(
+			screen,
+			l,
+			&text.GoTextFace{
+				Source: misakiFont,
+				Size:   fontSize,
+			},
+			op,
+		)
+	}
+}
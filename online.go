@@ -0,0 +1,355 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"log"
+
+	"github.com/shuuuta/shimanuke-chuta/online"
+	"github.com/shuuuta/shimanuke-chuta/sim"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/text/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// onlineBuffer is the number of the messages queued in a connection.
+const onlineBuffer = 256
+
+// onlineConn is the connection to the relay server. It connects in the
+// background, and none of the methods block.
+type onlineConn interface {
+	// write sends m. A position may be dropped when the connection is behind,
+	// but not the last one of the run nor the other messages.
+	write(m online.Message)
+	// read returns the next message received, if any.
+	read() (online.Message, bool)
+	// error returns why the connection has been lost.
+	error() error
+	close()
+}
+
+// ghost is another player of the online race.
+type ghost struct {
+	online.Player
+	x16      int
+	distance int
+	over     bool
+	goal     bool
+	left     bool
+}
+
+// onlineRace is the state of the online race, from the lobby to the result.
+type onlineRace struct {
+	conn onlineConn
+	id   int
+
+	// In the lobby
+	players []online.Player
+	count   int
+
+	ghosts  map[int]*ghost
+	ranking []online.Standing
+}
+
+// startOnline connects to the relay and waits in the lobby.
+func (g *Game) startOnline() {
+	g.init(g.config(newSeed()))
+	g.run = runOnline
+	g.online = &onlineRace{conn: dialOnline(g.relayURL)}
+	g.online.conn.write(online.Message{Type: online.TypeJoin, Name: g.playerName})
+	g.mode = ModeOnlineLobby
+}
+
+// startOnlineRace starts the run of the race the relay has started.
+func (g *Game) startOnlineRace(m online.Message) {
+	o := g.online
+	g.online = nil
+	g.init(sim.Config{Seed: m.Seed, Difficulty: m.Difficulty})
+	g.run = runOnline
+	g.online = o
+
+	o.ghosts = map[int]*ghost{}
+	for _, p := range m.Players {
+		if p.ID != o.id {
+			o.ghosts[p.ID] = &ghost{Player: p, x16: g.world.X16}
+		}
+	}
+	g.mode = ModeGame
+}
+
+// receiveOnline handles the messages from the relay.
+func (g *Game) receiveOnline() {
+	o := g.online
+	for {
+		m, ok := o.conn.read()
+		if !ok {
+			return
+		}
+		switch m.Type {
+		case online.TypeWelcome:
+			o.id = m.ID
+		case online.TypeLobby:
+			o.players = m.Players
+			o.count = 0
+		case online.TypeCountdown:
+			o.count = m.Count
+		case online.TypeStart:
+			g.startOnlineRace(m)
+		case online.TypePosition:
+			if gh, ok := o.ghosts[m.ID]; ok {
+				gh.x16 = m.X16
+				gh.distance = m.Distance
+				gh.over = m.Over
+				gh.goal = m.Goal
+			}
+		case online.TypeLeave:
+			if gh, ok := o.ghosts[m.ID]; ok {
+				gh.left = true
+			}
+		case online.TypeResult:
+			o.ranking = m.Ranking
+		case online.TypeError:
+			log.Printf("Relay: %s", m.Error)
+		}
+	}
+}
+
+func (g *Game) updateOnlineLobby() {
+	g.receiveOnline()
+	if g.mode != ModeOnlineLobby {
+		return
+	}
+	if g.isActionJustPressed(actionPause) {
+		g.quit()
+		return
+	}
+	if p, ok := g.justPressedPosition(); ok && p.In(quitButton) {
+		g.quit()
+	}
+}
+
+// updateOnlineGame advances the run of the online race. It can't be paused,
+// as the others don't wait.
+func (g *Game) updateOnlineGame() {
+	g.receiveOnline()
+
+	in, _ := g.input()
+	g.step(in)
+	g.online.conn.write(online.Position(g.world))
+
+	// The results of online races are not records, as they are not of the
+	// selected difficulty.
+	if g.world.Over || g.world.Goal {
+		g.counter = 0
+		g.mode = ModeGameOver
+	}
+}
+
+func (g *Game) updateOnlineResult() {
+	g.receiveOnline()
+	if g.counter == seGameOverDelay {
+		if g.world.Goal {
+			g.sound.playSE("arrive")
+		} else {
+			g.sound.playSE("gameover")
+		}
+	}
+	if g.counter > gameOverWait && g.isSelectJustPressed() {
+		g.quit()
+	}
+}
+
+// drawGhosts draws the boats of the others, translucent, where they are
+// compared to the player.
+func (g *Game) drawGhosts(screen *ebiten.Image) {
+	w := g.world
+	y0 := float64(w.Y16/16 - w.CameraY)
+	for _, gh := range g.online.ghosts {
+		if gh.left {
+			continue
+		}
+		x := float64(gh.x16/16 - g.cameraX)
+		y := y0 - float64(gh.distance-w.Distance())/float64(sim.PxToTravelDistance(1))
+		alpha := float32(0.4)
+		if gh.over {
+			alpha = 0.15
+		}
+		drawGhostBoat(screen, x, y, alpha, gh.Name)
+	}
+}
+
+func (g *Game) drawOnlineLobby(screen *ebiten.Image) {
+	o := g.online
+	vector.DrawFilledRect(screen, 0, 0, screenWidth, screenHeight, color.RGBA{0, 0, 0, 128}, false)
+
+	op := &text.DrawOptions{}
+	op.GeoM.Translate(screenWidth/2, 64)
+	op.ColorScale.ScaleWithColor(color.White)
+	op.LineSpacing = middleFontSize
+	op.PrimaryAlign = text.AlignCenter
+	text.Draw(
+		screen,
+		"オンライン対戦",
+		&text.GoTextFace{
+			Source: k8x12sFont,
+			Size:   middleFontSize,
+		},
+		op,
+	)
+
+	var status string
+	switch {
+	case o.conn.error() != nil:
+		status = "中継サーバーにつながりません"
+	case o.id == 0:
+		status = "接続中..."
+	case o.count > 0:
+		status = fmt.Sprintf("スタートまで %d", o.count)
+	default:
+		status = "対戦相手を待っています"
+	}
+	lines := []string{status}
+	if len(o.players) > 0 {
+		lines = append(lines, "")
+	}
+	for _, p := range o.players {
+		name := p.Name
+		if p.ID == o.id {
+			name += " (あなた)"
+		}
+		lines = append(lines, name)
+	}
+
+	textY := 64 + middleFontSize + 32
+	for _, l := range lines {
+		op := &text.DrawOptions{}
+		op.GeoM.Translate(screenWidth/2, textY)
+		op.ColorScale.ScaleWithColor(color.White)
+		op.LineSpacing = fontSize
+		op.PrimaryAlign = text.AlignCenter
+		text.Draw(
+			screen,
+			l,
+			&text.GoTextFace{
+				Source: misakiFont,
+				Size:   fontSize,
+			},
+			op,
+		)
+		textY += fontSize + 8
+	}
+
+	r := quitButton
+	vector.StrokeRect(screen, float32(r.Min.X), float32(r.Min.Y), float32(r.Dx()), float32(r.Dy()), 2, color.White, false)
+	op = &text.DrawOptions{}
+	op.GeoM.Translate(float64(r.Min.X+r.Dx()/2), float64(r.Min.Y+r.Dy()/2))
+	op.ColorScale.ScaleWithColor(color.White)
+	op.LineSpacing = fontSize
+	op.PrimaryAlign = text.AlignCenter
+	op.SecondaryAlign = text.AlignCenter
+	text.Draw(
+		screen,
+		"やめる",
+		&text.GoTextFace{
+			Source: misakiFont,
+			Size:   fontSize,
+		},
+		op,
+	)
+}
+
+// drawOnlineResult draws the ranking of the race, or how far the player has
+// got while the others are still on the way.
+func (g *Game) drawOnlineResult(screen *ebiten.Image) {
+	o := g.online
+	w := g.world
+	vector.DrawFilledRect(screen, 0, 0, screenWidth, screenHeight, color.RGBA{0, 0, 0, 80}, false)
+
+	title := "沈没"
+	if w.Goal {
+		title = "到着!"
+	}
+	lines := []string{fmt.Sprintf("%s %.1fkm", w.Location, float64(w.Distance())/1000)}
+	switch {
+	case o.ranking != nil:
+		for i, s := range o.ranking {
+			if s.ID == o.id {
+				title = fmt.Sprintf("%d位", i+1)
+			}
+		}
+		lines = append(lines, "")
+		for i, s := range o.ranking {
+			result := fmt.Sprintf("%.1fkm", float64(s.Distance)/1000)
+			switch {
+			case s.Left:
+				result = "切断"
+			case s.Goal:
+				result = fmt.Sprintf("到着 %.1f秒", float64(s.Frame)/60)
+			}
+			mark := ""
+			if s.ID == o.id {
+				mark = "→"
+			}
+			lines = append(lines, fmt.Sprintf("%s%d. %s %s", mark, i+1, s.Name, result))
+		}
+	case o.conn.error() != nil:
+		lines = append(lines, "", "中継サーバーとの", "接続が切れました")
+	default:
+		lines = append(lines, "", "他のプレイヤーを待っています")
+	}
+
+	textY := 128.0
+
+	op := &text.DrawOptions{}
+	op.GeoM.Translate(screenWidth/2, textY)
+	op.ColorScale.ScaleWithColor(color.White)
+	op.LineSpacing = titleFontSize
+	op.PrimaryAlign = text.AlignCenter
+	text.Draw(
+		screen,
+		title,
+		&text.GoTextFace{
+			Source: k8x12sFont,
+			Size:   titleFontSize,
+		},
+		op,
+	)
+
+	textY += titleFontSize + 24
+
+	for _, l := range lines {
+		op := &text.DrawOptions{}
+		op.GeoM.Translate(screenWidth/2, textY)
+		op.ColorScale.ScaleWithColor(color.White)
+		op.LineSpacing = fontSize
+		op.PrimaryAlign = text.AlignCenter
+		text.Draw(
+			screen,
+			l,
+			&text.GoTextFace{
+				Source: misakiFont,
+				Size:   fontSize,
+			},
+			op,
+		)
+		textY += fontSize + 8
+	}
+
+	if g.counter > gameOverWait && (g.counter-gameOverWait)%100 < 50 {
+		op := &text.DrawOptions{}
+		op.GeoM.Translate(screenWidth/2, screenHeight-96)
+		op.ColorScale.ScaleWithColor(color.White)
+		op.LineSpacing = fontSize
+		op.PrimaryAlign = text.AlignCenter
+		text.Draw(
+			screen,
+			"Tap to start menu",
+			&text.GoTextFace{
+				Source: misakiFont,
+				Size:   fontSize,
+			},
+			op,
+		)
+	}
+}
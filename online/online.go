@@ -0,0 +1,94 @@
+// Package online is the protocol of the online ghost race, where every
+// client plays its own run of the same seed and the relay server passes the
+// positions of the boats to the other clients.
+//
+// The messages are JSON text messages over WebSocket. A client sends
+// TypeJoin to wait in the lobby. When enough players are waiting, the server
+// counts down with TypeCountdown and sends TypeStart to all of them. During
+// the race, every client sends TypePosition every frame and receives the ones
+// of the others, and the server sends TypeResult when everyone has finished,
+// sunk or left.
+package online
+
+import "github.com/shuuuta/shimanuke-chuta/sim"
+
+// Type is the type of a message.
+type Type string
+
+const (
+	// TypeJoin is sent by a client to wait for the next race with Name.
+	TypeJoin Type = "join"
+	// TypeWelcome tells a client its ID.
+	TypeWelcome Type = "welcome"
+	// TypeLobby tells the Players waiting for the next race.
+	TypeLobby Type = "lobby"
+	// TypeCountdown tells the seconds left in Count before the race starts.
+	TypeCountdown Type = "countdown"
+	// TypeStart starts the race of Seed and Difficulty with Players.
+	TypeStart Type = "start"
+	// TypePosition is the boat of a player in a frame. The server fills ID
+	// when it passes the message to the others.
+	TypePosition Type = "pos"
+	// TypeLeave tells that the player of ID has disconnected.
+	TypeLeave Type = "leave"
+	// TypeResult is the final Ranking of the race.
+	TypeResult Type = "result"
+	// TypeError tells what was wrong with a message in Error.
+	TypeError Type = "error"
+)
+
+// Player is a player connected to the server.
+type Player struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// Standing is how far a player has got in a race.
+type Standing struct {
+	Player
+	// Distance is the travelled distance in meters, and Frame is the frame
+	// the player has finished at.
+	Distance int  `json:"distance"`
+	Frame    int  `json:"frame"`
+	Over     bool `json:"over,omitempty"`
+	Goal     bool `json:"goal,omitempty"`
+	// Left is set when the player has disconnected before finishing.
+	Left bool `json:"left,omitempty"`
+}
+
+// Message is a message between a client and the server. Only the fields of
+// the type are set.
+type Message struct {
+	Type Type `json:"type"`
+
+	ID      int      `json:"id,omitempty"`
+	Name    string   `json:"name,omitempty"`
+	Players []Player `json:"players,omitempty"`
+	Count   int      `json:"count,omitempty"`
+
+	// For TypeStart
+	Seed       uint64         `json:"seed,omitempty"`
+	Difficulty sim.Difficulty `json:"difficulty,omitempty"`
+
+	// For TypePosition. Distance is in meters.
+	Frame    int  `json:"frame,omitempty"`
+	X16      int  `json:"x16,omitempty"`
+	Distance int  `json:"distance,omitempty"`
+	Over     bool `json:"over,omitempty"`
+	Goal     bool `json:"goal,omitempty"`
+
+	Ranking []Standing `json:"ranking,omitempty"`
+	Error   string     `json:"error,omitempty"`
+}
+
+// Position returns the position message of the player in w.
+func Position(w *sim.World) Message {
+	return Message{
+		Type:     TypePosition,
+		Frame:    w.Frame,
+		X16:      w.X16,
+		Distance: w.Distance(),
+		Over:     w.Over,
+		Goal:     w.Goal,
+	}
+}
@@ -0,0 +1,57 @@
+package websocket
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestEcho(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := Upgrade(w, r)
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		for {
+			msg, err := c.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err := c.WriteMessage(msg); err != nil {
+				return
+			}
+		}
+	}))
+	defer s.Close()
+
+	c, err := Dial("ws" + strings.TrimPrefix(s.URL, "http"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// The lengths in 7 bits, 16 bits and 64 bits
+	for _, n := range []int{0, 5, 125, 126, 1000, 0x10000} {
+		want := bytes.Repeat([]byte("a"), n)
+		if err := c.WriteMessage(want); err != nil {
+			t.Fatal(err)
+		}
+		got, err := c.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("message of %d bytes: got %d bytes", n, len(got))
+		}
+	}
+}
+
+func TestAcceptKey(t *testing.T) {
+	// The example in RFC 6455
+	if got, want := acceptKey("dGhlIHNhbXBsZSBub25jZQ=="), "s3pPLMBiTxaQ9kYGzzhZRbK+xOo="; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,153 @@
+//go:build js
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"syscall/js"
+
+	"github.com/shuuuta/shimanuke-chuta/online"
+)
+
+// jsConn is the connection to the relay with the WebSocket of the browser.
+type jsConn struct {
+	ws    js.Value
+	funcs []js.Func
+	recv  chan online.Message
+
+	mu sync.Mutex
+	// queue holds the messages sent before the connection opens.
+	queue []online.Message
+	// inbox holds the messages received that must not be dropped but didn't
+	// fit in recv.
+	inbox []online.Message
+	open  bool
+	err   error
+}
+
+func dialOnline(url string) (conn onlineConn) {
+	c := &jsConn{
+		recv: make(chan online.Message, onlineBuffer),
+	}
+	defer func() {
+		// The constructor throws for a wrong URL.
+		if r := recover(); r != nil {
+			c.err = fmt.Errorf("WebSocket: %v", r)
+			conn = c
+		}
+	}()
+	c.ws = js.Global().Get("WebSocket").New(url)
+
+	c.on("open", func(js.Value) {
+		c.mu.Lock()
+		c.open = true
+		queue := c.queue
+		c.queue = nil
+		c.mu.Unlock()
+		for _, m := range queue {
+			c.write(m)
+		}
+	})
+	c.on("message", func(e js.Value) {
+		var m online.Message
+		if err := json.Unmarshal([]byte(e.Get("data").String()), &m); err != nil {
+			log.Printf("Failed to decode a message from the relay: %v", err)
+			return
+		}
+		// Event handlers must not block.
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if len(c.inbox) == 0 {
+			select {
+			case c.recv <- m:
+				return
+			default:
+			}
+		}
+		// Positions come again in the next frame.
+		if m.Type == online.TypePosition {
+			return
+		}
+		c.inbox = append(c.inbox, m)
+	})
+	c.on("close", func(js.Value) {
+		c.mu.Lock()
+		if c.err == nil {
+			c.err = errors.New("WebSocket: closed")
+		}
+		c.mu.Unlock()
+		// No more events come after close.
+		for _, f := range c.funcs {
+			f.Release()
+		}
+	})
+	return c
+}
+
+func (c *jsConn) on(event string, f func(e js.Value)) {
+	fn := js.FuncOf(func(this js.Value, args []js.Value) any {
+		f(args[0])
+		return nil
+	})
+	c.funcs = append(c.funcs, fn)
+	c.ws.Call("addEventListener", event, fn)
+}
+
+func (c *jsConn) write(m online.Message) {
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return
+	}
+	if !c.open {
+		c.queue = append(c.queue, m)
+		c.mu.Unlock()
+		return
+	}
+	c.mu.Unlock()
+
+	b, err := json.Marshal(m)
+	if err != nil {
+		panic(err)
+	}
+	c.ws.Call("send", string(b))
+}
+
+func (c *jsConn) read() (online.Message, bool) {
+	select {
+	case m := <-c.recv:
+		return m, true
+	default:
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.inbox) == 0 {
+		return online.Message{}, false
+	}
+	m := c.inbox[0]
+	c.inbox = c.inbox[1:]
+	return m, true
+}
+
+func (c *jsConn) error() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+func (c *jsConn) close() {
+	if c.ws.IsUndefined() {
+		return
+	}
+	c.mu.Lock()
+	if c.err == nil {
+		c.err = errors.New("WebSocket: closed")
+	}
+	c.mu.Unlock()
+	c.ws.Call("close")
+	c.ws = js.Undefined()
+}
@@ -0,0 +1,165 @@
+//go:build !js
+
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+
+	"github.com/shuuuta/shimanuke-chuta/online"
+	"github.com/shuuuta/shimanuke-chuta/online/websocket"
+)
+
+// wsConn is the connection to the relay with the WebSocket in Go.
+type wsConn struct {
+	recv chan online.Message
+	send chan online.Message
+	wake chan struct{}
+	done chan struct{}
+	once sync.Once
+
+	mu   sync.Mutex
+	conn *websocket.Conn
+	err  error
+	// pending holds the messages that must not be dropped but didn't fit in
+	// send.
+	pending []online.Message
+}
+
+func dialOnline(url string) onlineConn {
+	c := &wsConn{
+		recv: make(chan online.Message, onlineBuffer),
+		send: make(chan online.Message, onlineBuffer),
+		wake: make(chan struct{}, 1),
+		done: make(chan struct{}),
+	}
+	go c.run(url)
+	return c
+}
+
+func (c *wsConn) setErr(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err == nil {
+		c.err = err
+	}
+}
+
+func (c *wsConn) run(url string) {
+	conn, err := websocket.Dial(url)
+	if err != nil {
+		c.setErr(err)
+		return
+	}
+	c.mu.Lock()
+	c.conn = conn
+	c.mu.Unlock()
+
+	go func() {
+		send := func(m online.Message) bool {
+			b, err := json.Marshal(m)
+			if err != nil {
+				panic(err)
+			}
+			if err := conn.WriteMessage(b); err != nil {
+				c.setErr(err)
+				return false
+			}
+			return true
+		}
+		for {
+			select {
+			case m := <-c.send:
+				if !send(m) {
+					return
+				}
+			case <-c.wake:
+				// The pending messages were written after the ones in send.
+				for len(c.send) > 0 {
+					if !send(<-c.send) {
+						return
+					}
+				}
+				c.mu.Lock()
+				pending := c.pending
+				c.pending = nil
+				c.mu.Unlock()
+				for _, m := range pending {
+					if !send(m) {
+						return
+					}
+				}
+			case <-c.done:
+				conn.Close()
+				return
+			}
+		}
+	}()
+
+	for {
+		b, err := conn.ReadMessage()
+		if err != nil {
+			c.setErr(err)
+			return
+		}
+		var m online.Message
+		if err := json.Unmarshal(b, &m); err != nil {
+			log.Printf("Failed to decode a message from the relay: %v", err)
+			continue
+		}
+		select {
+		case c.recv <- m:
+		case <-c.done:
+			return
+		}
+	}
+}
+
+func (c *wsConn) write(m online.Message) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.pending) == 0 {
+		select {
+		case c.send <- m:
+			return
+		default:
+		}
+	}
+	// Positions come again in the next frame, but the last one tells the
+	// relay that the player has finished.
+	if m.Type == online.TypePosition && !m.Over && !m.Goal {
+		return
+	}
+	c.pending = append(c.pending, m)
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (c *wsConn) read() (online.Message, bool) {
+	select {
+	case m := <-c.recv:
+		return m, true
+	default:
+		return online.Message{}, false
+	}
+}
+
+func (c *wsConn) error() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+func (c *wsConn) close() {
+	c.once.Do(func() {
+		close(c.done)
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.conn != nil {
+			c.conn.Close()
+		}
+	})
+}
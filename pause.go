@@ -0,0 +1,102 @@
+package main
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/text/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+var (
+	pauseButton  = image.Rect(screenWidth-56, 8, screenWidth-8, 56)
+	resumeButton = image.Rect(screenWidth/2-120, 288, screenWidth/2+120, 352)
+	quitButton   = image.Rect(screenWidth/2-120, 384, screenWidth/2+120, 448)
+)
+
+func (g *Game) updatePause() {
+	if g.isActionJustPressed(actionPause) || g.isActionJustPressed(actionSelect) {
+		g.mode = ModeGame
+		return
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyQ) {
+		g.quit()
+		return
+	}
+
+	p, ok := g.justPressedPosition()
+	if !ok {
+		return
+	}
+	if g.run == runRace {
+		// Every half of the screen has the buttons.
+		p.X %= screenWidth
+	}
+	if p.In(resumeButton) {
+		g.mode = ModeGame
+		return
+	}
+	if p.In(quitButton) {
+		g.quit()
+	}
+}
+
+// quit abandons the run without recording it.
+func (g *Game) quit() {
+	g.init(g.config(newSeed()))
+	g.mode = ModeStartMenu
+}
+
+func (g *Game) drawPauseButton(screen *ebiten.Image) {
+	r := pauseButton
+	barWidth := float32(r.Dx()) / 4
+	vector.DrawFilledRect(screen, float32(r.Min.X)+barWidth/2, float32(r.Min.Y)+8, barWidth, float32(r.Dy())-16, color.White, false)
+	vector.DrawFilledRect(screen, float32(r.Max.X)-barWidth*3/2, float32(r.Min.Y)+8, barWidth, float32(r.Dy())-16, color.White, false)
+}
+
+func (g *Game) drawPause(screen *ebiten.Image) {
+	vector.DrawFilledRect(screen, 0, 0, screenWidth, screenHeight, color.RGBA{0, 0, 0, 128}, false)
+
+	op := &text.DrawOptions{}
+	op.GeoM.Translate(screenWidth/2, 128)
+	op.ColorScale.ScaleWithColor(color.White)
+	op.LineSpacing = titleFontSize
+	op.PrimaryAlign = text.AlignCenter
+	text.Draw(
+		screen,
+		"PAUSE",
+		&text.GoTextFace{
+			Source: k8x12sFont,
+			Size:   titleFontSize,
+		},
+		op,
+	)
+
+	for _, b := range []struct {
+		rect  image.Rectangle
+		label string
+	}{
+		{resumeButton, "再開"},
+		{quitButton, "やめる"},
+	} {
+		vector.StrokeRect(screen, float32(b.rect.Min.X), float32(b.rect.Min.Y), float32(b.rect.Dx()), float32(b.rect.Dy()), 2, color.White, false)
+
+		op = &text.DrawOptions{}
+		op.GeoM.Translate(float64(b.rect.Min.X+b.rect.Dx()/2), float64(b.rect.Min.Y+b.rect.Dy()/2))
+		op.ColorScale.ScaleWithColor(color.White)
+		op.LineSpacing = fontSize
+		op.PrimaryAlign = text.AlignCenter
+		op.SecondaryAlign = text.AlignCenter
+		text.Draw(
+			screen,
+			b.label,
+			&text.GoTextFace{
+				Source: misakiFont,
+				Size:   fontSize,
+			},
+			op,
+		)
+	}
+}
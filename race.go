@@ -0,0 +1,304 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+
+	"github.com/shuuuta/shimanuke-chuta/sim"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/text/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// racePlayers is the number of the players of a race on the same device. The
+// screen is split into a half for each of them.
+const racePlayers = 2
+
+// raceKeys are the keys to stroke to the left and to the right of each player.
+var raceKeys = [racePlayers][2]ebiten.Key{
+	{ebiten.KeyA, ebiten.KeyD},
+	{ebiten.KeyArrowLeft, ebiten.KeyArrowRight},
+}
+
+var raceColors = [racePlayers]color.RGBA{
+	{0xff, 0x70, 0x70, 0xff},
+	{0x70, 0xb0, 0xff, 0xff},
+}
+
+// raceController is the input of a player of a race: their keys, the gamepad
+// in the same order as the players, and taps on their half of the screen.
+type raceController struct {
+	g      *Game
+	player int
+}
+
+// Input implements sim.Controller.
+func (c raceController) Input(*sim.World) sim.Input {
+	g := c.g
+	in := sim.Input{
+		Left:  inpututil.IsKeyJustPressed(raceKeys[c.player][0]),
+		Right: inpututil.IsKeyJustPressed(raceKeys[c.player][1]),
+	}
+	if c.player < len(g.gamepadIDs) {
+		id := g.gamepadIDs[c.player]
+		in.Left = in.Left || g.bindings[actionLeft].isButtonJustPressed(id)
+		in.Right = in.Right || g.bindings[actionRight].isButtonJustPressed(id)
+	}
+	for _, id := range g.touchIDs {
+		x, _ := ebiten.TouchPosition(id)
+		if x/screenWidth != c.player {
+			continue
+		}
+		if x%screenWidth < screenWidth/2 {
+			in.Left = true
+		} else {
+			in.Right = true
+		}
+	}
+	return in
+}
+
+// startRace starts a race of the players from the first stage in the
+// selected difficulty.
+func (g *Game) startRace() {
+	g.init(g.config(newSeed()))
+	g.run = runRace
+	g.race = sim.NewRace(g.config(g.world.Seed), racePlayers)
+	for _, w := range g.race.Worlds {
+		w.Invincible = muteki
+	}
+	g.world = g.race.Worlds[0]
+	g.mode = ModeGame
+	ebiten.SetWindowSize(screenWidth*racePlayers, screenHeight)
+}
+
+func (g *Game) updateRace() {
+	if g.isActionJustPressed(actionPause) || !ebiten.IsFocused() {
+		g.mode = ModePause
+		return
+	}
+
+	for i, w := range g.race.Worlds {
+		if w.Over || w.Goal {
+			continue
+		}
+		g.world = w
+		g.step(raceController{g, i}.Input(w))
+	}
+	g.world = g.race.Worlds[0]
+
+	if g.race.Done() {
+		g.counter = 0
+		g.mode = ModeGameOver
+	}
+}
+
+func (g *Game) updateRaceResult() {
+	if g.counter == seGameOverDelay {
+		g.sound.playSE("arrive")
+	}
+	if g.counter > gameOverWait && g.isSelectJustPressed() {
+		g.quit()
+	}
+}
+
+// raceScreen returns the offscreen image of the half of the player.
+func (g *Game) raceScreen(player int) *ebiten.Image {
+	if g.raceScreens[player] == nil {
+		g.raceScreens[player] = ebiten.NewImage(screenWidth, screenHeight)
+	}
+	return g.raceScreens[player]
+}
+
+// drawRace draws the world of each player on their half of the screen, with
+// the same functions as the ones of a single player.
+func (g *Game) drawRace(screen *ebiten.Image) {
+	defer func(w *sim.World) {
+		g.world = w
+	}(g.world)
+
+	ranks := g.race.Ranks()
+	for i, w := range g.race.Worlds {
+		g.world = w
+		half := g.raceScreen(i)
+		half.Clear()
+
+		g.drawWaves(half)
+		g.drawSurfs(half)
+		g.drawObstacles(half)
+		g.drawItems(half)
+		g.drawGameScreen(half)
+		g.drawPlayer(half)
+		g.drawRacePlayer(half, i)
+
+		switch g.mode {
+		case ModePause:
+			g.drawPause(half)
+		case ModeGameOver:
+			g.drawRaceResult(half, i, ranks)
+		}
+
+		op := &ebiten.DrawImageOptions{}
+		op.GeoM.Translate(float64(i*screenWidth), 0)
+		screen.DrawImage(half, op)
+	}
+
+	for i := 1; i < racePlayers; i++ {
+		vector.StrokeLine(screen, float32(i*screenWidth), 0, float32(i*screenWidth), screenHeight, 4, color.Black, false)
+	}
+}
+
+// drawRacePlayer draws the name of the player over the boat, and what has
+// become of the player when they are not on the way anymore.
+func (g *Game) drawRacePlayer(screen *ebiten.Image, player int) {
+	w := g.world
+	m := g.playerGeoM()
+	x, y := m.Apply(playerWidth/2, 0)
+
+	op := &text.DrawOptions{}
+	op.GeoM.Translate(x, y-4)
+	op.ColorScale.ScaleWithColor(raceColors[player])
+	op.LineSpacing = hudFontSize
+	op.PrimaryAlign = text.AlignCenter
+	op.SecondaryAlign = text.AlignEnd
+	text.Draw(
+		screen,
+		fmt.Sprintf("%dP", player+1),
+		&text.GoTextFace{
+			Source: misakiFont,
+			Size:   hudFontSize,
+		},
+		op,
+	)
+
+	if !w.Over || g.mode == ModeGameOver {
+		return
+	}
+	vector.DrawFilledRect(screen, 0, 0, screenWidth, screenHeight, color.RGBA{0, 0, 0, 50}, false)
+
+	op = &text.DrawOptions{}
+	op.GeoM.Translate(screenWidth/2, screenHeight/2)
+	op.ColorScale.ScaleWithColor(color.White)
+	op.LineSpacing = middleFontSize
+	op.PrimaryAlign = text.AlignCenter
+	op.SecondaryAlign = text.AlignCenter
+	text.Draw(
+		screen,
+		fmt.Sprintf("沈没 %.1fkm", float64(w.Distance())/1000),
+		&text.GoTextFace{
+			Source: k8x12sFont,
+			Size:   middleFontSize,
+		},
+		op,
+	)
+}
+
+// drawRaceResult draws how far the player has got and their rank in ranks.
+func (g *Game) drawRaceResult(screen *ebiten.Image, player int, ranks []int) {
+	w := g.world
+	vector.DrawFilledRect(screen, 0, 0, screenWidth, screenHeight, color.RGBA{0, 0, 0, 50}, false)
+
+	title := fmt.Sprintf("%d位", ranks[player])
+	if ranks[player] == 1 {
+		title = "勝ち!"
+		for i, r := range ranks {
+			if i != player && r == 1 {
+				title = "引き分け"
+			}
+		}
+	}
+
+	textY := 128.0
+
+	op := &text.DrawOptions{}
+	op.GeoM.Translate(screenWidth/2, textY)
+	op.ColorScale.ScaleWithColor(raceColors[player])
+	op.LineSpacing = middleFontSize
+	op.PrimaryAlign = text.AlignCenter
+	text.Draw(
+		screen,
+		fmt.Sprintf("%dP", player+1),
+		&text.GoTextFace{
+			Source: k8x12sFont,
+			Size:   middleFontSize,
+		},
+		op,
+	)
+
+	textY += middleFontSize + 16
+
+	op = &text.DrawOptions{}
+	op.GeoM.Translate(screenWidth/2, textY)
+	op.ColorScale.ScaleWithColor(color.White)
+	op.LineSpacing = titleFontSize
+	op.PrimaryAlign = text.AlignCenter
+	text.Draw(
+		screen,
+		title,
+		&text.GoTextFace{
+			Source: k8x12sFont,
+			Size:   titleFontSize,
+		},
+		op,
+	)
+
+	textY += titleFontSize + 24
+
+	result := w.Location + " 到達"
+	if w.Goal {
+		result = fmt.Sprintf("%s 到着 %.1f秒", w.Location, float64(w.Frame)/60)
+	}
+
+	op = &text.DrawOptions{}
+	op.GeoM.Translate(screenWidth/2, textY)
+	op.ColorScale.ScaleWithColor(color.White)
+	op.LineSpacing = fontSize
+	op.PrimaryAlign = text.AlignCenter
+	text.Draw(
+		screen,
+		result,
+		&text.GoTextFace{
+			Source: misakiFont,
+			Size:   fontSize,
+		},
+		op,
+	)
+
+	textY += fontSize + 24
+
+	op = &text.DrawOptions{}
+	op.GeoM.Translate(screenWidth/2, textY)
+	op.ColorScale.ScaleWithColor(color.White)
+	op.LineSpacing = middleFontSize
+	op.PrimaryAlign = text.AlignCenter
+	text.Draw(
+		screen,
+		fmt.Sprintf("%.1fkm", float64(w.Distance())/1000),
+		&text.GoTextFace{
+			Source: misakiFont,
+			Size:   middleFontSize,
+		},
+		op,
+	)
+
+	if g.counter > gameOverWait && (g.counter-gameOverWait)%100 < 50 {
+		textY += middleFontSize + 64
+
+		op = &text.DrawOptions{}
+		op.GeoM.Translate(screenWidth/2, textY)
+		op.ColorScale.ScaleWithColor(color.White)
+		op.LineSpacing = fontSize
+		op.PrimaryAlign = text.AlignCenter
+		text.Draw(
+			screen,
+			"Tap to start menu",
+			&text.GoTextFace{
+				Source: misakiFont,
+				Size:   fontSize,
+			},
+			op,
+		)
+	}
+}
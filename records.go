@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/shuuuta/shimanuke-chuta/sim"
+)
+
+const recordsKey = "records"
+
+// record is the best result of a mode.
+type record struct {
+	// Distance is the best distance in meters.
+	Distance int `json:"distance"`
+	// Island is the furthest island reached.
+	Island string `json:"island"`
+}
+
+func (r record) String() string {
+	if r.Island == "" {
+		return fmt.Sprintf("BEST %.1fkm", float64(r.Distance)/1000)
+	}
+	return fmt.Sprintf("BEST %.1fkm %s", float64(r.Distance)/1000, r.Island)
+}
+
+// records holds the record of each mode.
+type records map[string]record
+
+func loadRecords() records {
+	r := records{}
+	loadJSON(recordsKey, &r)
+
+	// Records before the difficulties were added are normal ones.
+	if rec, ok := r["normal"]; ok {
+		delete(r, "normal")
+		if _, ok := r[normalRecordMode(sim.DifficultyNormal)]; !ok {
+			r[normalRecordMode(sim.DifficultyNormal)] = rec
+		}
+		r.save()
+	}
+	return r
+}
+
+func (r records) save() {
+	saveJSON(recordsKey, r)
+}
+
+// update stores the result of a run and reports whether it is a new best
+// distance.
+func (r records) update(mode string, w *sim.World) bool {
+	old := r[mode]
+	rec := old
+	newRecord := w.Distance() > old.Distance
+	if newRecord {
+		rec.Distance = w.Distance()
+	}
+	if stageIndex(w.Stages, w.Location) > stageIndex(w.Stages, old.Island) {
+		rec.Island = w.Location
+	}
+	if rec != old {
+		r[mode] = rec
+		r.save()
+	}
+	return newRecord
+}
+
+// stageIndex returns the index of the stage named name, or -1.
+func stageIndex(stages sim.Stages, name string) int {
+	for i, s := range stages {
+		if s.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// reached returns the index of the furthest stage of stages reached in any
+// mode. An endless record of a waypoint has gone past the last stage.
+func (r records) reached(stages sim.Stages) int {
+	reached := 0
+	for mode, rec := range r {
+		i := stageIndex(stages, rec.Island)
+		if i < 0 && rec.Island != "" && strings.HasPrefix(mode, "endless/") {
+			i = len(stages) - 1
+		}
+		reached = max(reached, i)
+	}
+	return reached
+}
+
+// normalRecordMode returns the mode of a normal run in the difficulty.
+func normalRecordMode(d sim.Difficulty) string {
+	return "normal/" + d.String()
+}
+
+// endlessRecordMode returns the mode of the endless mode in the difficulty.
+func endlessRecordMode(d sim.Difficulty) string {
+	return "endless/" + d.String()
+}
+
+// dailyRecordMode returns the mode of the daily challenge of the date in the
+// difficulty.
+func dailyRecordMode(date string, d sim.Difficulty) string {
+	return "daily/" + date + "/" + d.String()
+}
+
+// pruneDaily removes the records of the daily challenges except the ones of
+// the date, so that only the records of today are kept.
+func (r records) pruneDaily(date string) {
+	for mode := range r {
+		if isStaleDaily(mode, date) {
+			delete(r, mode)
+		}
+	}
+}
+
+// isStaleDaily reports whether the mode is a daily challenge of another date
+// than the date.
+func isStaleDaily(mode, date string) bool {
+	return strings.HasPrefix(mode, "daily/") && !strings.HasPrefix(mode, "daily/"+date+"/")
+}
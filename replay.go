@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"io/fs"
+	"os"
+	"time"
+
+	"github.com/shuuuta/shimanuke-chuta/sim"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// replaySaveButton is the area of the save button on the game over screen.
+var replaySaveButton = image.Rect(0, screenHeight-96, screenWidth, screenHeight-32)
+
+// input returns the input for the next frame, from the controller or from
+// the replay being played back. It returns false when the replay has ended.
+func (g *Game) input() (sim.Input, bool) {
+	if g.replay != nil {
+		return g.replay.Input(g.world.Frame)
+	}
+	return g.controller.Input(g.world), true
+}
+
+// startReplay starts playing back r from the beginning.
+func (g *Game) startReplay(r *sim.Replay) {
+	g.init(r.Config())
+	switch {
+	case r.StartStage > 0:
+		g.run = runPractice
+	case r.Endless:
+		g.run = runEndless
+	}
+	g.replay = r
+	g.mode = ModeGame
+}
+
+func (g *Game) isReplaySaveJustPressed() bool {
+	if g.replay != nil {
+		return false
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyS) {
+		return true
+	}
+	p, ok := g.justPressedPosition()
+	return ok && p.In(replaySaveButton)
+}
+
+func (g *Game) saveReplay() {
+	b, err := g.recording.MarshalBinary()
+	if err != nil {
+		g.replayMessage = "保存できませんでした"
+		return
+	}
+	name := fmt.Sprintf("shimanuke-chuta-%s.bin", time.Now().Format("20060102-150405"))
+	if err := saveReplayFile(name, b); err != nil {
+		g.replayMessage = "保存できませんでした"
+		return
+	}
+	g.replayMessage = name
+}
+
+func loadReplayFile(name string) (*sim.Replay, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	r := &sim.Replay{}
+	if err := r.UnmarshalBinary(b); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// droppedReplay returns the replay dropped onto the window, or nil.
+func droppedReplay() (*sim.Replay, error) {
+	files := ebiten.DroppedFiles()
+	if files == nil {
+		return nil, nil
+	}
+	entries, err := fs.ReadDir(files, ".")
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		b, err := fs.ReadFile(files, e.Name())
+		if err != nil {
+			return nil, err
+		}
+		r := &sim.Replay{}
+		if err := r.UnmarshalBinary(b); err != nil {
+			return nil, err
+		}
+		return r, nil
+	}
+	return nil, nil
+}
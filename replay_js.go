@@ -0,0 +1,30 @@
+//go:build js
+
+package main
+
+import (
+	"syscall/js"
+)
+
+// saveReplayFile lets the browser download the replay.
+func saveReplayFile(name string, data []byte) error {
+	array := js.Global().Get("Uint8Array").New(len(data))
+	js.CopyBytesToJS(array, data)
+
+	blob := js.Global().Get("Blob").New(
+		[]any{array},
+		map[string]any{"type": "application/octet-stream"},
+	)
+	u := js.Global().Get("URL")
+	url := u.Call("createObjectURL", blob)
+
+	a := js.Global().Get("document").Call("createElement", "a")
+	a.Set("href", url)
+	a.Set("download", name)
+	a.Call("click")
+
+	// Revoke the URL after the download has started.
+	revoke := u.Get("revokeObjectURL").Call("bind", u, url)
+	js.Global().Call("setTimeout", revoke, 1000)
+	return nil
+}
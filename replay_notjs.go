@@ -0,0 +1,28 @@
+//go:build !js
+
+package main
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// saveReplayFile writes the replay into the replays directory under the
+// user config directory.
+func saveReplayFile(name string, data []byte) error {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return err
+	}
+	dir = filepath.Join(dir, "shimanuke-chuta", "replays")
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return err
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0666); err != nil {
+		return err
+	}
+	log.Printf("Saved replay to %s", path)
+	return nil
+}
@@ -0,0 +1,15 @@
+// Package resources holds the sprites shared by the game and the simulation,
+// which makes collision masks from them.
+package resources
+
+import (
+	_ "embed"
+)
+
+var (
+	//go:embed tiles.png
+	TilesPNG []byte
+
+	//go:embed player.png
+	PlayerPNG []byte
+)
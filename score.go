@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shuuuta/shimanuke-chuta/leaderboard"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/text/v2"
+)
+
+const scoreTimeout = 10 * time.Second
+
+// scoreSubmission is the submission of the score of a run in the background.
+type scoreSubmission struct {
+	mu     sync.Mutex
+	status string
+}
+
+func (s *scoreSubmission) setStatus(status string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status = status
+}
+
+func (s *scoreSubmission) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status
+}
+
+// submitScore sends the result of the run with its replay to the score
+// server, which plays the replay back to check it.
+func (g *Game) submitScore() {
+	replay, err := g.recording.MarshalBinary()
+	if err != nil {
+		log.Printf("Failed to encode the replay: %v", err)
+		return
+	}
+	w := g.world
+	body, err := json.Marshal(leaderboard.Submission{
+		Name:       g.playerName,
+		Distance:   w.Distance(),
+		Island:     w.Location,
+		Frame:      w.Frame,
+		Seed:       w.Seed,
+		Difficulty: w.Difficulty,
+		Endless:    w.Endless,
+		Replay:     replay,
+		ReplayHash: leaderboard.HashReplay(replay),
+	})
+	if err != nil {
+		log.Printf("Failed to encode the score: %v", err)
+		return
+	}
+
+	s := &scoreSubmission{status: "スコア送信中..."}
+	g.score = s
+	url := strings.TrimSuffix(g.scoreServer, "/") + "/scores"
+	key := g.scoreKey
+	go func() {
+		if err := postScore(url, key, body); err != nil {
+			log.Printf("Failed to submit the score: %v", err)
+			s.setStatus("スコアを送れませんでした")
+			return
+		}
+		s.setStatus("スコアを送りました")
+	}()
+}
+
+func postScore(url, key string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		req.Header.Set(leaderboard.SignatureHeader, leaderboard.Sign([]byte(key), body))
+	}
+	c := &http.Client{Timeout: scoreTimeout}
+	res, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusCreated {
+		var e struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(res.Body).Decode(&e)
+		return fmt.Errorf("%s: %s", res.Status, e.Error)
+	}
+	return nil
+}
+
+// drawScoreStatus draws how the submission of the score is going.
+func (g *Game) drawScoreStatus(screen *ebiten.Image) {
+	if g.score == nil {
+		return
+	}
+	op := &text.DrawOptions{}
+	op.GeoM.Translate(screenWidth/2, float64(replaySaveButton.Max.Y+8))
+	op.ColorScale.ScaleWithColor(color.White)
+	op.LineSpacing = fontSize
+	op.PrimaryAlign = text.AlignCenter
+	op.SecondaryAlign = text.AlignCenter
+	text.Draw(
+		screen,
+		g.score.String(),
+		&text.GoTextFace{
+			Source: misakiFont,
+			Size:   fontSize,
+		},
+		op,
+	)
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/text/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+const settingsTop = 160
+
+// The rows of the settings after the actions.
+const (
+	settingsReset = int(actionCount) + iota
+	settingsBack
+
+	settingsRows
+)
+
+func (g *Game) updateSettings() {
+	if g.waitingBinding {
+		// Escape or a click cancels.
+		if _, ok := g.justPressedPosition(); ok {
+			g.waitingBinding = false
+			return
+		}
+		b, ok := g.justPressedKeyOrButton()
+		if !ok {
+			return
+		}
+		g.waitingBinding = false
+		if len(b.Keys) > 0 && b.Keys[0] == ebiten.KeyEscape {
+			return
+		}
+
+		// The sound keys are refused, and another key is waited for.
+		if !g.bindings.bind(action(g.settingsCursor), b) {
+			g.sound.playSE("hit")
+			g.waitingBinding = true
+			return
+		}
+		g.bindings.save()
+		return
+	}
+
+	i := g.updateMenu(&g.settingsCursor, settingsRows, settingsTop)
+	switch {
+	case i < 0:
+	case i < int(actionCount):
+		g.waitingBinding = true
+	case i == settingsReset:
+		g.bindings = defaultBindings()
+		g.bindings.save()
+	case i == settingsBack:
+		g.mode = ModeStartMenu
+	}
+}
+
+func (g *Game) drawSettings(screen *ebiten.Image) {
+	vector.DrawFilledRect(screen, 0, 0, screenWidth, screenHeight, color.RGBA{0, 0, 0, 128}, false)
+
+	op := &text.DrawOptions{}
+	op.GeoM.Translate(screenWidth/2, 64)
+	op.ColorScale.ScaleWithColor(color.White)
+	op.LineSpacing = middleFontSize
+	op.PrimaryAlign = text.AlignCenter
+	text.Draw(
+		screen,
+		"キー設定",
+		&text.GoTextFace{
+			Source: k8x12sFont,
+			Size:   middleFontSize,
+		},
+		op,
+	)
+
+	labels := make([]string, settingsRows)
+	for a := action(0); a < actionCount; a++ {
+		b := g.bindings[a].String()
+		if g.waitingBinding && int(a) == g.settingsCursor {
+			b = "?"
+		}
+		labels[a] = actionNames[a] + ": " + b
+	}
+	labels[settingsReset] = "初期設定に戻す"
+	labels[settingsBack] = "もどる"
+	drawMenu(screen, labels, g.settingsCursor, settingsTop)
+
+	hint := "決定で変更"
+	if g.waitingBinding {
+		hint = "キーかボタンを押す (Esc:取消)"
+	}
+	op = &text.DrawOptions{}
+	op.GeoM.Translate(screenWidth/2, screenHeight-64)
+	op.ColorScale.ScaleWithColor(color.White)
+	op.LineSpacing = fontSize
+	op.PrimaryAlign = text.AlignCenter
+	text.Draw(
+		screen,
+		hint,
+		&text.GoTextFace{
+			Source: misakiFont,
+			Size:   fontSize,
+		},
+		op,
+	)
+}
@@ -0,0 +1,266 @@
+package sim
+
+import (
+	"image"
+	"slices"
+)
+
+// Controller decides the player's input of every frame.
+type Controller interface {
+	Input(w *World) Input
+}
+
+// botHorizon is how many frames ahead the bot looks at most.
+const botHorizon = 240
+
+// botMargin keeps the boat off the sides of the gaps and the obstacles,
+// where the corners of the tilted sprite touch them.
+const botMargin = 12
+
+// Bot is a Controller that plays the game by itself. Every frame, it tries
+// not stroking and stroking to each side, plays each of them out a few
+// seconds ahead with the waves, the surfs, the obstacles and the velocity of
+// the world, and picks the one that keeps the boat in the gaps. It reaches
+// 東京 in most runs of easy and normal, but as it doesn't look farther ahead,
+// it sometimes sinks, more often in hard.
+type Bot struct {
+	// TapInterval is the frames between strokes at least, like a human.
+	TapInterval int
+
+	lastTap int
+}
+
+// NewBot returns a bot stroking as fast as the surfs are generated for.
+func NewBot() *Bot {
+	return &Bot{
+		TapInterval: tapInterval,
+		lastTap:     -tapInterval,
+	}
+}
+
+// Input implements Controller.
+func (b *Bot) Input(w *World) Input {
+	// The input is used in the next step.
+	frame := w.Frame + 1
+	options := []Input{{}}
+	if frame-b.lastTap >= b.TapInterval {
+		options = append(options, Input{Left: true}, Input{Right: true})
+	}
+	// Which side of the obstacles to pass, when there are any.
+	sides := []int{0}
+	if len(w.Obstacles) > 0 {
+		sides = []int{0, -1, 1}
+	}
+
+	best, bestCost := Input{}, -1
+	for _, in := range options {
+		for _, side := range sides {
+			cost := b.playOut(w, in, side)
+			if in.Left || in.Right {
+				// Don't stroke for nothing.
+				cost++
+			}
+			if bestCost < 0 || cost < bestCost {
+				best, bestCost = in, cost
+			}
+		}
+	}
+	if best.Left || best.Right {
+		b.lastTap = frame
+	}
+	return best
+}
+
+// playOut moves the boat ahead with in as the first input, then stroking
+// towards the next gap, or to the side of the next obstacle, whenever it
+// drifts away. side is -1 to pass the closest obstacle on the left, 1 on the
+// right and 0 to ignore the obstacles. The ones farther are passed on the side
+// closer to the gap after them. It returns how badly the boat misses the gaps
+// and hits the edges of the screen and the obstacles.
+func (b *Bot) playOut(w *World, in Input, side int) int {
+	py := w.Y16/16 - w.CameraY
+	x16, vx16 := w.X16, w.VX16
+	lastTap := 0
+	if !in.Left && !in.Right {
+		lastTap = b.lastTap - w.Frame - 1
+	}
+
+	// The surfs the boat has not passed yet.
+	var surfs []*Surf
+	for _, s := range w.Surfs {
+		if s.Y+w.CameraY < py+PlayerHeight {
+			surfs = append(surfs, s)
+		}
+	}
+
+	// The obstacles that sink the boat, and where the driftwood will be.
+	var obstacles []*Obstacle
+	var ox16, ovx16 []int
+	for _, o := range w.Obstacles {
+		if o.Mask() == nil || o.Y+w.CameraY >= py+PlayerHeight {
+			continue
+		}
+		obstacles = append(obstacles, o)
+		ox16 = append(ox16, o.X16)
+		ovx16 = append(ovx16, o.VX16)
+	}
+
+	// The items the boat may pick up on the way.
+	items := slices.Clone(w.Items)
+	calm, boost := w.Calm, w.Boost
+
+	// The closest obstacle, which is passed on the side.
+	first := -1
+	if len(surfs) > 0 {
+		first = nextObstacle(obstacles, surfs[0], py, w.CameraY)
+	}
+
+	// The stage the boat is in, as it speeds up in the next ones.
+	stage := 0
+	for stage+1 < len(w.Stages) && w.Stages[stage+1].Dist*1000 <= w.Distance() {
+		stage++
+	}
+
+	cost := 0
+	cameraY, y16 := w.CameraY, w.Y16
+	for f := 0; f < botHorizon; f++ {
+		calm = max(calm-1, 0)
+		boost = max(boost-1, 0)
+		for stage+1 < len(w.Stages) && w.Stages[stage+1].Dist*1000 <= TravelDistance(y16) {
+			stage++
+		}
+		speed := w.Stages[stage].Speed
+		if boost > 0 {
+			speed *= BoostSpeed
+		}
+		cameraY += speed
+		y16 += speed * 16
+
+		for len(surfs) > 0 && surfs[0].Y+cameraY >= py+PlayerHeight {
+			surfs = surfs[1:]
+		}
+		if len(surfs) == 0 {
+			break
+		}
+		next := surfs[0]
+		x0, x1 := passage(next.LeftWidth, next.Gap)
+		x0 += botMargin
+		x1 -= botMargin
+
+		for i, o := range obstacles {
+			if o.Kind == ObstacleDriftwood {
+				ox16[i], ovx16[i] = driftwoodStep(ox16[i], ovx16[i])
+			}
+		}
+
+		if f > 0 && f-lastTap >= b.TapInterval {
+			center := (x0 + x1) / 2
+			tolerance := (x1 - x0) / 4
+			if i := nextObstacle(obstacles, next, py, cameraY); side != 0 && i >= 0 {
+				bounds := obstacles[i].Mask().Bounds()
+				left := ox16[i]/16 + bounds.Min.X - botMargin - PlayerWidth - PlayerWidth/2
+				right := ox16[i]/16 + bounds.Max.X + botMargin + PlayerWidth/2
+				s := side
+				if i != first {
+					s = 1
+					if abs(left-center) < abs(right-center) {
+						s = -1
+					}
+				}
+				// Towards the gap, as long as it is on the side.
+				if s < 0 {
+					center = min(center, left)
+				} else {
+					center = max(center, right)
+				}
+				center = min(max(center, botMargin), ScreenWidth-PlayerWidth-botMargin)
+				tolerance = PlayerWidth / 4
+			}
+			in = Input{}
+			switch {
+			case x16 < (center-tolerance)*16 && vx16 < w.MaxVX16/2:
+				in.Right = true
+			case x16 > (center+tolerance)*16 && vx16 > -w.MaxVX16/2:
+				in.Left = true
+			}
+		}
+		if in.Right {
+			vx16 = w.MaxVX16
+			lastTap = f
+		}
+		if in.Left {
+			vx16 = -w.MaxVX16
+			lastTap = f
+		}
+		in = Input{}
+
+		x16 = min(max(x16+vx16, 0), (ScreenWidth-PlayerWidth)*16)
+		if calm > 0 {
+			vx16 = w.calmDrag(vx16)
+		} else {
+			vx16 += w.waveDirectionAt(cameraY)
+		}
+		vx16 += w.whirlpoolPull(x16, cameraY)
+		vx16 = min(max(vx16, -w.MaxVX16), w.MaxVX16)
+
+		x := x16 / 16
+		if x <= 0 || x+PlayerWidth >= ScreenWidth {
+			// It is over, so the later frames don't matter.
+			return cost + 10000*(botHorizon-f)
+		}
+		items = slices.DeleteFunc(items, func(it *Item) bool {
+			if !it.rect(cameraY).Overlaps(pickupRect(x, py)) {
+				return false
+			}
+			switch it.Kind {
+			case ItemCalm:
+				calm = CalmFrames
+			case ItemBoost:
+				boost = BoostFrames
+			}
+			return true
+		})
+		if boost > 0 {
+			// It rides over the surfs and the obstacles.
+			continue
+		}
+		for i, o := range obstacles {
+			r := o.Mask().Bounds().Add(image.Pt(ox16[i]/16, o.Y+cameraY))
+			if r.Max.Y > py && r.Min.Y < py+PlayerHeight && r.Max.X > x-botMargin/2 && r.Min.X < x+PlayerWidth+botMargin/2 {
+				return cost + 10000*(botHorizon-f)
+			}
+		}
+		if sy := next.Y + cameraY; sy+SurfHeight > py {
+			// The boat is in the surf.
+			if x < x0 {
+				cost += x0 - x + 100
+			}
+			if x > x1 {
+				cost += x - x1 + 100
+			}
+		}
+	}
+	return cost
+}
+
+// nextObstacle returns the index of the closest obstacle ahead of the boat
+// before the surf, or -1.
+func nextObstacle(obstacles []*Obstacle, next *Surf, py, cameraY int) int {
+	found := -1
+	for i, o := range obstacles {
+		if o.Y+cameraY >= py+PlayerHeight || o.Y < next.Y {
+			continue
+		}
+		if found < 0 || o.Y > obstacles[found].Y {
+			found = i
+		}
+	}
+	return found
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
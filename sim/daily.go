@@ -0,0 +1,22 @@
+package sim
+
+import (
+	"hash/fnv"
+	"time"
+)
+
+// dailyZone is the time zone that a day of the daily challenge follows, so
+// that everyone plays the same course on the same day.
+var dailyZone = time.FixedZone("JST", 9*60*60)
+
+// DailyDate returns the date of the daily challenge at t, as "2006-01-02".
+func DailyDate(t time.Time) string {
+	return t.In(dailyZone).Format(time.DateOnly)
+}
+
+// DailySeed returns the seed of the daily challenge of the date.
+func DailySeed(date string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte("shimanuke-chuta/daily/" + date))
+	return h.Sum64()
+}
@@ -0,0 +1,117 @@
+package sim
+
+import (
+	"fmt"
+	"math"
+)
+
+// Difficulty scales the parameters of every stage and of the player's
+// movement. The zero value is DifficultyNormal.
+type Difficulty int
+
+const (
+	DifficultyNormal Difficulty = iota
+	DifficultyEasy
+	DifficultyHard
+)
+
+// Difficulties are the difficulties from the easiest.
+var Difficulties = []Difficulty{DifficultyEasy, DifficultyNormal, DifficultyHard}
+
+// Profile is the multipliers of a difficulty.
+type Profile struct {
+	Speed        float64
+	SurfGap      float64
+	SurfInterval float64
+	WavePush     float64
+	MaxVX        float64
+	Swing        float64
+	// Obstacles multiplies the chances of the obstacles.
+	Obstacles float64
+	// Items multiplies the chances of the power-ups.
+	Items float64
+}
+
+var profiles = map[Difficulty]Profile{
+	DifficultyEasy: {
+		Speed:        0.7,
+		SurfGap:      1.25,
+		SurfInterval: 1.2,
+		WavePush:     0.5,
+		MaxVX:        1,
+		Swing:        0.7,
+		Obstacles:    0.5,
+		Items:        1.5,
+	},
+	DifficultyNormal: {
+		Speed:        1,
+		SurfGap:      1,
+		SurfInterval: 1,
+		WavePush:     1,
+		MaxVX:        1,
+		Swing:        1,
+		Obstacles:    1,
+		Items:        1,
+	},
+	DifficultyHard: {
+		Speed:        1.3,
+		SurfGap:      0.85,
+		SurfInterval: 0.9,
+		WavePush:     1.5,
+		MaxVX:        0.9,
+		Swing:        1.15,
+		Obstacles:    1.5,
+		Items:        0.75,
+	},
+}
+
+func (d Difficulty) String() string {
+	switch d {
+	case DifficultyNormal:
+		return "normal"
+	case DifficultyEasy:
+		return "easy"
+	case DifficultyHard:
+		return "hard"
+	}
+	return fmt.Sprintf("Difficulty(%d)", int(d))
+}
+
+// Valid reports whether d is a known difficulty.
+func (d Difficulty) Valid() bool {
+	_, ok := profiles[d]
+	return ok
+}
+
+// Profile returns the multipliers of the difficulty. Unknown difficulties are
+// treated as DifficultyNormal.
+func (d Difficulty) Profile() Profile {
+	if p, ok := profiles[d]; ok {
+		return p
+	}
+	return profiles[DifficultyNormal]
+}
+
+func scale(v int, r float64, lo, hi int) int {
+	return min(max(int(math.Round(float64(v)*r)), lo), hi)
+}
+
+// Scaled returns the stages with the parameters multiplied by the profile.
+// The results are kept in the range that Validate accepts.
+func (s Stages) Scaled(p Profile) Stages {
+	scaled := make(Stages, len(s))
+	for i, v := range s {
+		v.Speed = scale(v.Speed, p.Speed, 1, math.MaxInt)
+		v.SurfGap = scale(v.SurfGap, p.SurfGap, minSurfGap, maxSurfGap)
+		v.SurfInterval = scale(v.SurfInterval, p.SurfInterval, 1, math.MaxInt)
+		v.Swing = min(v.Swing*p.Swing, 1)
+		v.Obstacles.Rock *= p.Obstacles
+		v.Obstacles.Whirlpool *= p.Obstacles
+		v.Obstacles.Driftwood *= p.Obstacles
+		v.Items.Shield *= p.Items
+		v.Items.Calm *= p.Items
+		v.Items.Boost *= p.Items
+		scaled[i] = v
+	}
+	return scaled
+}
@@ -0,0 +1,29 @@
+package sim
+
+import "testing"
+
+func TestDifficultyScalesStages(t *testing.T) {
+	normal := New(Config{Seed: 1})
+	for _, d := range Difficulties {
+		w := New(Config{Seed: 1, Difficulty: d})
+		if err := w.Stages.Validate(); err != nil {
+			t.Errorf("%s: %v", d, err)
+		}
+		if d == DifficultyNormal {
+			continue
+		}
+		easier := d == DifficultyEasy
+		for i, s := range w.Stages {
+			n := normal.Stages[i]
+			if (s.Speed < n.Speed) != easier && s.Speed != n.Speed {
+				t.Errorf("%s: %s speed %d, normal %d", d, s.Name, s.Speed, n.Speed)
+			}
+			if (s.SurfGap > n.SurfGap) != easier && s.SurfGap != n.SurfGap {
+				t.Errorf("%s: %s surfGap %d, normal %d", d, s.Name, s.SurfGap, n.SurfGap)
+			}
+		}
+		if (w.WavePush < normal.WavePush) != easier {
+			t.Errorf("%s: wave push %d, normal %d", d, w.WavePush, normal.WavePush)
+		}
+	}
+}
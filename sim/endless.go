@@ -0,0 +1,105 @@
+package sim
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Endless is how the route goes on past the last stage in the endless mode.
+// The extra distance is split into waypoints, each of which is a bit harder
+// than the previous one until the limits.
+type Endless struct {
+	// Interval is the distance between waypoints in km.
+	Interval int `json:"interval"`
+
+	MaxSpeed        int `json:"maxSpeed"`
+	MinSurfGap      int `json:"minSurfGap"`
+	MinSurfInterval int `json:"minSurfInterval"`
+
+	// Waypoints are the names of the waypoints. They are repeated with the
+	// lap number when the run goes past all of them.
+	Waypoints []string `json:"waypoints"`
+}
+
+// ParseEndless parses and validates the "endless" object in JSON.
+func ParseEndless(data []byte) (Endless, error) {
+	var f struct {
+		Endless *Endless `json:"endless"`
+	}
+	if err := json.Unmarshal(data, &f); err != nil {
+		return Endless{}, err
+	}
+	if f.Endless == nil {
+		return Endless{}, errors.New("no endless")
+	}
+	if err := f.Endless.Validate(); err != nil {
+		return Endless{}, err
+	}
+	return *f.Endless, nil
+}
+
+// Validate reports whether the endless mode can go on forever.
+func (e Endless) Validate() error {
+	var errs []error
+	if e.Interval <= 0 {
+		errs = append(errs, fmt.Errorf("endless: interval must be positive, got %d", e.Interval))
+	}
+	if e.MaxSpeed <= 0 {
+		errs = append(errs, fmt.Errorf("endless: maxSpeed must be positive, got %d", e.MaxSpeed))
+	}
+	if e.MinSurfGap < minSurfGap || e.MinSurfGap > maxSurfGap {
+		errs = append(errs, fmt.Errorf("endless: minSurfGap must be in [%d, %d], got %d", minSurfGap, maxSurfGap, e.MinSurfGap))
+	}
+	if e.MinSurfInterval <= 0 {
+		errs = append(errs, fmt.Errorf("endless: minSurfInterval must be positive, got %d", e.MinSurfInterval))
+	}
+	if len(e.Waypoints) == 0 {
+		errs = append(errs, errors.New("endless: no waypoints"))
+	}
+	for i, name := range e.Waypoints {
+		if name == "" {
+			errs = append(errs, fmt.Errorf("endless: waypoint #%d: name is empty", i))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Waypoint returns the n-th waypoint, counted from 0, after prev. Every
+// waypoint is faster and has shorter intervals of surfs than prev, and the
+// gaps narrow at every other waypoint. Parameters already past the limits
+// are kept as they are.
+func (e Endless) Waypoint(prev Stage, n int) Stage {
+	name := e.Waypoints[n%len(e.Waypoints)]
+	if lap := n / len(e.Waypoints); lap > 0 {
+		name = fmt.Sprintf("%s %d", name, lap+1)
+	}
+
+	s := Stage{
+		Name:         name,
+		Dist:         prev.Dist + e.Interval,
+		Speed:        max(min(prev.Speed+1, e.MaxSpeed), prev.Speed),
+		SurfGap:      prev.SurfGap,
+		SurfInterval: min(max(prev.SurfInterval-1, e.MinSurfInterval), prev.SurfInterval),
+		Swing:        prev.Swing,
+		Obstacles:    prev.Obstacles,
+		Items:        prev.Items,
+	}
+	if n%2 == 1 {
+		s.SurfGap = min(max(prev.SurfGap-1, e.MinSurfGap), prev.SurfGap)
+	}
+	return s
+}
+
+// extendStages appends waypoints in the endless mode until the last stage is
+// further than dist meters.
+func (w *World) extendStages(dist int) {
+	if !w.Endless {
+		return
+	}
+	for w.Stages[len(w.Stages)-1].Dist*1000 <= dist {
+		w.lastStage = w.endless.Waypoint(w.lastStage, w.waypoints)
+		w.waypoints++
+		w.Stages = append(w.Stages, Stages{w.lastStage}.Scaled(w.profile)...)
+	}
+}
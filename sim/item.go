@@ -0,0 +1,199 @@
+package sim
+
+import (
+	"image"
+	"math/rand/v2"
+	"slices"
+)
+
+// ItemKind is the type of a power-up between surfs.
+type ItemKind int
+
+const (
+	// ItemShield absorbs a hit.
+	ItemShield ItemKind = iota
+	// ItemCalm stops the waves pushing the boat for a while.
+	ItemCalm
+	// ItemBoost carries the boat faster over the surfs and the obstacles for
+	// a while.
+	ItemBoost
+)
+
+func (k ItemKind) String() string {
+	switch k {
+	case ItemShield:
+		return "shield"
+	case ItemCalm:
+		return "calm"
+	case ItemBoost:
+		return "boost"
+	}
+	return "unknown"
+}
+
+const (
+	ItemSize = 32
+
+	// CalmFrames and BoostFrames are how long the items last.
+	CalmFrames  = 5 * 60
+	BoostFrames = 3 * 60
+	// RecoverFrames is how long the boat ignores hits after the shield has
+	// absorbed one, to get away from what it has hit.
+	RecoverFrames = 60
+
+	// BoostSpeed multiplies the speed while boosting.
+	BoostSpeed = 2
+)
+
+// The sprites of the items in tiles.png, from ItemShield.
+const (
+	itemX = 128
+	itemY = 128
+)
+
+// ItemWeights are the chances of each item between two surfs, relative to
+// None.
+type ItemWeights struct {
+	None   float64 `json:"none"`
+	Shield float64 `json:"shield"`
+	Calm   float64 `json:"calm"`
+	Boost  float64 `json:"boost"`
+}
+
+func (ws ItemWeights) valid() bool {
+	return ws.None >= 0 && ws.Shield >= 0 && ws.Calm >= 0 && ws.Boost >= 0
+}
+
+// pick returns an item at random, or false for none. Like
+// ObstacleWeights.pick, it doesn't use rng when there can be no items.
+func (ws ItemWeights) pick(rng *rand.Rand) (ItemKind, bool) {
+	kinds := []struct {
+		kind   ItemKind
+		weight float64
+	}{
+		{ItemShield, ws.Shield},
+		{ItemCalm, ws.Calm},
+		{ItemBoost, ws.Boost},
+	}
+	total := ws.None
+	for _, k := range kinds {
+		total += k.weight
+	}
+	if total == ws.None {
+		return 0, false
+	}
+	r := rng.Float64() * total
+	for _, k := range kinds {
+		if r < k.weight {
+			return k.kind, true
+		}
+		r -= k.weight
+	}
+	return 0, false
+}
+
+// Item is a power-up between surfs, picked up by touching it.
+type Item struct {
+	Kind ItemKind
+	// X is the left of the item, and Y is the top like the one of Surf.
+	X int
+	Y int
+}
+
+// Sprite returns the area of the item's sprite in tiles.png.
+func (it *Item) Sprite() image.Rectangle {
+	x := itemX + int(it.Kind)*ItemSize
+	return image.Rect(x, itemY, x+ItemSize, itemY+ItemSize)
+}
+
+// addItem may place an item of the stage between prev and the surf above it,
+// on the way from a gap to the other and clear of the obstacle there.
+func (w *World) addItem(prev, next *Surf, s Stage) {
+	kind, ok := s.Items.pick(w.rng)
+	if !ok {
+		return
+	}
+	it := &Item{Kind: kind}
+
+	top := next.Y + SurfHeight
+	bottom := prev.Y
+	if len(w.Obstacles) > 0 {
+		if o := w.Obstacles[len(w.Obstacles)-1]; o.Y > top && o.Y < bottom {
+			// Above the obstacle, which is in the middle.
+			bottom = o.Y
+		}
+	}
+	if bottom-top < ItemSize {
+		return
+	}
+	it.Y = (top+bottom)/2 - ItemSize/2
+
+	a0, a1 := passage(prev.LeftWidth, prev.Gap)
+	b0, b1 := passage(next.LeftWidth, next.Gap)
+	x0, x1 := min(a0, b0), max(a1, b1)+PlayerWidth-ItemSize
+	it.X = x0 + w.rng.IntN(x1-x0+1)
+	w.Items = append(w.Items, it)
+	if kind == ItemBoost {
+		w.lastBoost = it
+	}
+}
+
+// rect returns the area of the item in the screen when the camera is at
+// cameraY.
+func (it *Item) rect(cameraY int) image.Rectangle {
+	return image.Rect(it.X, it.Y+cameraY, it.X+ItemSize, it.Y+cameraY+ItemSize)
+}
+
+// pickupRect returns the area where the player at px and py touches items.
+func pickupRect(px, py int) image.Rectangle {
+	return image.Rect(px, py, px+PlayerWidth, py+PlayerHeight).Inset(PlayerWidth / 8)
+}
+
+// pickItems picks up the items the player touches.
+func (w *World) pickItems() {
+	player := pickupRect(w.X16/16, w.Y16/16-w.CameraY)
+
+	w.Picked = w.Picked[:0]
+	w.Items = slices.DeleteFunc(w.Items, func(it *Item) bool {
+		if !it.rect(w.CameraY).Overlaps(player) {
+			return false
+		}
+		switch it.Kind {
+		case ItemShield:
+			w.Shield = true
+		case ItemCalm:
+			w.Calm = CalmFrames
+		case ItemBoost:
+			w.Boost = BoostFrames
+		}
+		w.Picked = append(w.Picked, it)
+		return true
+	})
+}
+
+// protected reports whether the power-ups save the player from hitting the
+// surf s or the obstacle o. Both are nil for the edges of the screen. It uses
+// up the shield.
+func (w *World) protected(s *Surf, o *Obstacle) bool {
+	if w.Recover > 0 {
+		return true
+	}
+	if w.Boost > 0 && (s != nil || o != nil) {
+		return true
+	}
+	if w.Shield {
+		w.Shield = false
+		w.Recover = RecoverFrames
+		return true
+	}
+	return false
+}
+
+// calmDrag returns vx16 slowed down on the calm sea, where no wave pushes
+// back against the strokes.
+func (w *World) calmDrag(vx16 int) int {
+	if vx16 > 0 {
+		return max(vx16-w.WavePush, 0)
+	}
+	return min(vx16+w.WavePush, 0)
+}
@@ -0,0 +1,53 @@
+package sim
+
+import "testing"
+
+func TestPickItems(t *testing.T) {
+	for _, tt := range []struct {
+		kind  ItemKind
+		check func(w *World) bool
+	}{
+		{ItemShield, func(w *World) bool { return w.Shield }},
+		{ItemCalm, func(w *World) bool { return w.Calm > 0 && w.WaveDirection() == 0 }},
+		{ItemBoost, func(w *World) bool { return w.Boost > 0 }},
+	} {
+		w := New(Config{Seed: 1})
+		speed := w.Speed
+		// Right in front of the player.
+		it := &Item{Kind: tt.kind, X: w.X16/16 + PlayerWidth/2 - ItemSize/2, Y: w.Y16/16 - 2*w.CameraY - ItemSize/2}
+		w.Items = append(w.Items, it)
+		w.Step(Input{})
+		if len(w.Picked) != 1 || w.Picked[0] != it || len(w.Items) != 0 {
+			t.Errorf("%s: not picked up", tt.kind)
+			continue
+		}
+		if !tt.check(w) {
+			t.Errorf("%s: not applied", tt.kind)
+		}
+		w.Step(Input{})
+		if tt.kind == ItemBoost && w.Speed != speed*BoostSpeed {
+			t.Errorf("boost: speed %d, want %d", w.Speed, speed*BoostSpeed)
+		}
+	}
+}
+
+func TestShieldAbsorbsHit(t *testing.T) {
+	w := New(Config{Seed: 1})
+	w.Shield = true
+	w.X16, w.VX16 = 0, -w.MaxVX16
+	w.Step(Input{})
+	if w.Over || w.Shield {
+		t.Fatalf("shield did not absorb the hit: over %v, shield %v", w.Over, w.Shield)
+	}
+	for w.Recover > 0 {
+		w.Step(Input{})
+		if w.Over {
+			t.Fatal("hit while recovering")
+		}
+	}
+	w.X16, w.VX16 = 0, -w.MaxVX16
+	w.Step(Input{})
+	if !w.Over {
+		t.Fatal("shield absorbed two hits")
+	}
+}
@@ -0,0 +1,258 @@
+package sim
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"log"
+	"math"
+
+	"github.com/shuuuta/shimanuke-chuta/resources"
+)
+
+// The columns of the surf tiles in tiles.png.
+const (
+	surfX1 = 128 // the left end of a right surf
+	surfX2 = 160 // the middle of a surf
+	surfX3 = 224 // the right end of a left surf
+
+	surfFrames = 2
+)
+
+// Mask is a collision mask made from the alpha channel of a sprite.
+type Mask struct {
+	Width  int
+	Height int
+	bits   []bool
+	bounds image.Rectangle
+}
+
+func newMask(img image.Image, r image.Rectangle) *Mask {
+	r = r.Intersect(img.Bounds())
+	m := &Mask{
+		Width:  r.Dx(),
+		Height: r.Dy(),
+		bits:   make([]bool, r.Dx()*r.Dy()),
+	}
+	for y := 0; y < m.Height; y++ {
+		for x := 0; x < m.Width; x++ {
+			_, _, _, a := img.At(r.Min.X+x, r.Min.Y+y).RGBA()
+			m.bits[y*m.Width+x] = a >= 0x8000
+		}
+	}
+	m.updateBounds()
+	return m
+}
+
+func (m *Mask) updateBounds() {
+	m.bounds = image.Rectangle{}
+	for y := 0; y < m.Height; y++ {
+		for x := 0; x < m.Width; x++ {
+			if m.bits[y*m.Width+x] {
+				m.bounds = m.bounds.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+}
+
+// Bounds returns the smallest rectangle containing all the solid pixels.
+func (m *Mask) Bounds() image.Rectangle {
+	return m.bounds
+}
+
+// At reports whether the pixel is solid. Pixels out of the mask are not.
+func (m *Mask) At(x, y int) bool {
+	if x < 0 || y < 0 || x >= m.Width || y >= m.Height {
+		return false
+	}
+	return m.bits[y*m.Width+x]
+}
+
+var (
+	// playerMasks are indexed by the row and the column of player.png.
+	playerMasks [3][4]*Mask
+
+	// surfMasks are the surf tiles solid in every animation frame.
+	surfMasks = map[int]*Mask{}
+)
+
+func init() {
+	pimg, err := png.Decode(bytes.NewReader(resources.PlayerPNG))
+	if err != nil {
+		log.Fatal(err)
+	}
+	for row := range playerMasks {
+		for col := range playerMasks[row] {
+			playerMasks[row][col] = newMask(pimg, image.Rect(col*PlayerWidth, row*PlayerHeight, (col+1)*PlayerWidth, (row+1)*PlayerHeight))
+		}
+	}
+
+	timg, err := png.Decode(bytes.NewReader(resources.TilesPNG))
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, sx := range []int{surfX1, surfX2, surfX3} {
+		var m *Mask
+		for f := 0; f < surfFrames; f++ {
+			fm := newMask(timg, image.Rect(sx, f*SurfHeight, sx+SurfWidth, (f+1)*SurfHeight))
+			if m == nil {
+				m = fm
+				continue
+			}
+			for i := range m.bits {
+				m.bits[i] = m.bits[i] && fm.bits[i]
+			}
+			m.updateBounds()
+		}
+		surfMasks[sx] = m
+	}
+
+	initObstacleMasks(timg)
+}
+
+// PlayerMask returns the mask of a frame of player.png.
+func PlayerMask(col, row int) *Mask {
+	return playerMasks[row][col]
+}
+
+// SurfMask returns the mask of the surf tile at the column sx of tiles.png.
+func SurfMask(sx int) *Mask {
+	return surfMasks[sx]
+}
+
+// PlayerFrame returns the column and the row of player.png to draw.
+func (w *World) PlayerFrame() (col, row int) {
+	if w.CountAfterClick < 30 {
+		return w.CameraY / w.Speed / 5 % 4, w.ShipDir
+	}
+	return w.CameraY / w.Speed / 10 % 4, 0
+}
+
+// PlayerAngle returns the rotation of the player's sprite around its center.
+func (w *World) PlayerAngle() float64 {
+	return float64(w.VX16) / MaxVX16 * math.Pi / 6
+}
+
+// angleShift is the fraction bits of the fixed-point sine and cosine.
+const angleShift = 16
+
+// playerSinCos returns the sine and the cosine of -PlayerAngle in fixed point
+// with angleShift fraction bits. They are computed with integers like the
+// rest of the world, as floats may be rounded differently on other machines,
+// and a replay must hit the same pixels wherever it is played back.
+func (w *World) playerSinCos() (sin, cos int64) {
+	const one = 1 << angleShift
+	const piOver6 = 34315 // π/6 in fixed point
+	x := -int64(w.VX16) * piOver6 / MaxVX16
+	x2 := x * x >> angleShift
+
+	// Taylor series, close enough for the tilts of the boat.
+	sin, cos = x, one
+	ts, tc := x, int64(one)
+	for i := int64(1); i <= 4; i++ {
+		ts = -ts * x2 >> angleShift / (2 * i * (2*i + 1))
+		tc = -tc * x2 >> angleShift / ((2*i - 1) * 2 * i)
+		sin += ts
+		cos += tc
+	}
+	return sin, cos
+}
+
+// SurfTile is a tile of a surf line.
+type SurfTile struct {
+	// X is the position in the screen.
+	X int
+	// SX is the column in tiles.png.
+	SX int
+}
+
+// Tiles returns the tiles of the surf line, from the left.
+func (s *Surf) Tiles() []SurfTile {
+	var tiles []SurfTile
+	for i := s.LeftWidth; i >= -1; i = i - 2 {
+		sx := surfX2
+		if i == s.LeftWidth {
+			sx = surfX3
+		}
+		tiles = append(tiles, SurfTile{X: i * TileSize, SX: sx})
+	}
+	for i := 0; i < ScreenWidth/TileSize-s.LeftWidth-s.Gap; i++ {
+		sx := surfX2
+		if i == 0 {
+			sx = surfX1
+		}
+		tiles = append(tiles, SurfTile{X: (i + s.LeftWidth + s.Gap) * TileSize, SX: sx})
+	}
+	return tiles
+}
+
+// part is a mask placed at x and y in the screen.
+type part struct {
+	m    *Mask
+	x, y int
+}
+
+// hitSurf reports whether a solid pixel of the player's sprite overlaps a
+// solid pixel of the surf. px and py is the top left of the sprite before
+// rotation, in the screen.
+func (w *World) hitSurf(s *Surf, px, py int) bool {
+	sy := s.Y + w.CameraY
+	tiles := s.Tiles()
+	parts := make([]part, len(tiles))
+	for i, t := range tiles {
+		parts[i] = part{surfMasks[t.SX], t.X, sy}
+	}
+	return w.hitParts(parts, px, py)
+}
+
+// hitObstacle is like hitSurf for an obstacle.
+func (w *World) hitObstacle(o *Obstacle, px, py int) bool {
+	m := o.Mask()
+	if m == nil {
+		return false
+	}
+	return w.hitParts([]part{{m, o.X16 / 16, o.Y + w.CameraY}}, px, py)
+}
+
+// hitParts reports whether a solid pixel of the player's sprite overlaps a
+// solid pixel of the parts.
+func (w *World) hitParts(parts []part, px, py int) bool {
+	var area image.Rectangle
+	for _, p := range parts {
+		area = area.Union(p.m.bounds.Add(image.Pt(p.x, p.y)))
+	}
+
+	// The rotated sprite fits in a circle around its center.
+	const r = PlayerWidth * 3 / 4
+	cx := px + PlayerWidth/2
+	cy := py + PlayerHeight/2
+	area = area.Intersect(image.Rect(cx-r, cy-r, cx+r, cy+r))
+	area = area.Intersect(image.Rect(0, math.MinInt32, ScreenWidth, math.MaxInt32))
+	if area.Empty() {
+		return false
+	}
+
+	col, row := w.PlayerFrame()
+	m := playerMasks[row][col]
+	sin, cos := w.playerSinCos()
+
+	for y := area.Min.Y; y < area.Max.Y; y++ {
+		for x := area.Min.X; x < area.Max.X; x++ {
+			// Rotate the center of the pixel back into the sprite. dx and dy
+			// are in half pixels, and the shifts round down.
+			dx := int64(2*(x-cx) + 1)
+			dy := int64(2*(y-cy) + 1)
+			lx := (dx*cos - dy*sin + PlayerWidth<<angleShift) >> (angleShift + 1)
+			ly := (dx*sin + dy*cos + PlayerHeight<<angleShift) >> (angleShift + 1)
+			if !m.At(int(lx), int(ly)) {
+				continue
+			}
+			for _, p := range parts {
+				if p.m.At(x-p.x, y-p.y) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
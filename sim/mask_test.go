@@ -0,0 +1,46 @@
+package sim
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPlayerSinCos(t *testing.T) {
+	w := New(Config{Seed: 1})
+	for vx := -w.MaxVX16; vx <= w.MaxVX16; vx++ {
+		w.VX16 = vx
+		sin, cos := w.playerSinCos()
+		wantSin, wantCos := math.Sincos(-w.PlayerAngle())
+		const one = 1 << angleShift
+		if math.Abs(float64(sin)/one-wantSin) > 1e-4 || math.Abs(float64(cos)/one-wantCos) > 1e-4 {
+			t.Errorf("VX16 %d: got %d, %d, want %f, %f", vx, sin, cos, wantSin, wantCos)
+		}
+	}
+}
+
+func TestHitRotated(t *testing.T) {
+	w := New(Config{Seed: 1})
+	const px, py = 200, 300
+	for _, tt := range []struct {
+		vx          int
+		first, last int
+	}{
+		{0, 178, 251},
+		{w.MaxVX16, 185, 260},
+		{-w.MaxVX16, 172, 249},
+	} {
+		w.VX16 = tt.vx
+		first, last := -1, -1
+		for x := 100; x < 300; x++ {
+			if w.hitParts([]part{{rockMasks[0], x, py}}, px, py) {
+				if first < 0 {
+					first = x
+				}
+				last = x
+			}
+		}
+		if first != tt.first || last != tt.last {
+			t.Errorf("VX16 %d: a rock hits from x %d to %d, want %d to %d", tt.vx, first, last, tt.first, tt.last)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package sim
+
+import (
+	"image"
+	"math/rand/v2"
+)
+
+// ObstacleKind is the type of an obstacle between surfs.
+type ObstacleKind int
+
+const (
+	// ObstacleRock is a small rock that sinks the boat.
+	ObstacleRock ObstacleKind = iota
+	// ObstacleWhirlpool pulls the boat towards its center.
+	ObstacleWhirlpool
+	// ObstacleDriftwood is a log drifting sideways that sinks the boat.
+	ObstacleDriftwood
+)
+
+func (k ObstacleKind) String() string {
+	switch k {
+	case ObstacleRock:
+		return "rock"
+	case ObstacleWhirlpool:
+		return "whirlpool"
+	case ObstacleDriftwood:
+		return "driftwood"
+	}
+	return "unknown"
+}
+
+const (
+	RockSize = 32
+
+	DriftwoodWidth  = 64
+	DriftwoodHeight = 32
+
+	WhirlpoolSize = 64
+	// WhirlpoolRadius is how far from its center a whirlpool pulls the player.
+	WhirlpoolRadius = 56
+	// WhirlpoolPull is how much a whirlpool changes vx16 every frame.
+	WhirlpoolPull = 3
+
+	// The speed of driftwood in 1/16 pixels per frame.
+	driftwoodMinVX16 = 8
+	driftwoodMaxVX16 = 24
+)
+
+// The sprites of the obstacles in tiles.png.
+const (
+	rockX     = 0
+	rockY     = 192
+	rockCount = 2
+
+	driftwoodX = 0
+	driftwoodY = 224
+
+	whirlpoolX      = 64
+	whirlpoolY      = 192
+	whirlpoolFrames = 2
+)
+
+var (
+	rockMasks     [rockCount]*Mask
+	driftwoodMask *Mask
+)
+
+func initObstacleMasks(tiles image.Image) {
+	for i := range rockMasks {
+		x := rockX + i*RockSize
+		rockMasks[i] = newMask(tiles, image.Rect(x, rockY, x+RockSize, rockY+RockSize))
+	}
+	driftwoodMask = newMask(tiles, image.Rect(driftwoodX, driftwoodY, driftwoodX+DriftwoodWidth, driftwoodY+DriftwoodHeight))
+}
+
+// ObstacleWeights are the chances of each obstacle between two surfs,
+// relative to None.
+type ObstacleWeights struct {
+	None      float64 `json:"none"`
+	Rock      float64 `json:"rock"`
+	Whirlpool float64 `json:"whirlpool"`
+	Driftwood float64 `json:"driftwood"`
+}
+
+func (ws ObstacleWeights) valid() bool {
+	return ws.None >= 0 && ws.Rock >= 0 && ws.Whirlpool >= 0 && ws.Driftwood >= 0
+}
+
+// pick returns an obstacle at random, or false for none. It doesn't use rng
+// when there can be no obstacles.
+func (ws ObstacleWeights) pick(rng *rand.Rand) (ObstacleKind, bool) {
+	kinds := []struct {
+		kind   ObstacleKind
+		weight float64
+	}{
+		{ObstacleRock, ws.Rock},
+		{ObstacleWhirlpool, ws.Whirlpool},
+		{ObstacleDriftwood, ws.Driftwood},
+	}
+	total := ws.None
+	for _, k := range kinds {
+		total += k.weight
+	}
+	if total == ws.None {
+		return 0, false
+	}
+	r := rng.Float64() * total
+	for _, k := range kinds {
+		if r < k.weight {
+			return k.kind, true
+		}
+		r -= k.weight
+	}
+	return 0, false
+}
+
+// Obstacle is a hazard between surfs.
+type Obstacle struct {
+	Kind ObstacleKind
+	// X16 is the left of the obstacle in 1/16 pixels, and Y is the top like
+	// the one of Surf.
+	X16 int
+	Y   int
+	// VX16 is the speed of driftwood.
+	VX16 int
+	// Variant is the sprite of a rock.
+	Variant int
+}
+
+// Size returns the size of the obstacle's sprite.
+func (o *Obstacle) Size() (width, height int) {
+	switch o.Kind {
+	case ObstacleRock:
+		return RockSize, RockSize
+	case ObstacleDriftwood:
+		return DriftwoodWidth, DriftwoodHeight
+	}
+	return WhirlpoolSize, WhirlpoolSize
+}
+
+// Sprite returns the area of the obstacle's sprite in tiles.png at the
+// frame of the animation.
+func (o *Obstacle) Sprite(frame int) image.Rectangle {
+	var x, y int
+	switch o.Kind {
+	case ObstacleRock:
+		x, y = rockX+o.Variant*RockSize, rockY
+	case ObstacleDriftwood:
+		x, y = driftwoodX, driftwoodY
+	case ObstacleWhirlpool:
+		x, y = whirlpoolX+frame%whirlpoolFrames*WhirlpoolSize, whirlpoolY
+	}
+	w, h := o.Size()
+	return image.Rect(x, y, x+w, y+h)
+}
+
+// Mask returns the collision mask of the obstacle, or nil for the ones that
+// don't sink the boat.
+func (o *Obstacle) Mask() *Mask {
+	switch o.Kind {
+	case ObstacleRock:
+		return rockMasks[o.Variant]
+	case ObstacleDriftwood:
+		return driftwoodMask
+	}
+	return nil
+}
+
+// addObstacle may place an obstacle of the kind between prev and the surf
+// above it.
+func (w *World) addObstacle(prev, next *Surf, s Stage, kind ObstacleKind) {
+	o := &Obstacle{Kind: kind}
+	width, height := o.Size()
+
+	// The obstacle is in the middle of the space between the surfs, with
+	// room for the boat above and below it.
+	top := next.Y + SurfHeight
+	if prev.Y-top < height+PlayerHeight*2 {
+		return
+	}
+	o.Y = (top+prev.Y)/2 - height/2
+
+	switch kind {
+	case ObstacleRock:
+		o.Variant = w.rng.IntN(rockCount)
+		o.X16 = w.rng.IntN(ScreenWidth-width) * 16
+		// Leave a way for the boat from a gap to the other.
+		if !w.aroundRock(prev, next, o, s) {
+			return
+		}
+	case ObstacleWhirlpool:
+		o.X16 = w.rng.IntN(ScreenWidth-width) * 16
+	case ObstacleDriftwood:
+		o.X16 = w.rng.IntN(ScreenWidth-width) * 16
+		o.VX16 = driftwoodMinVX16 + w.rng.IntN(driftwoodMaxVX16-driftwoodMinVX16+1)
+		if w.rng.IntN(2) == 0 {
+			o.VX16 = -o.VX16
+		}
+	}
+	w.Obstacles = append(w.Obstacles, o)
+}
+
+// moveObstacles drifts the driftwood, bouncing at the edges of the screen.
+func (w *World) moveObstacles() {
+	for _, o := range w.Obstacles {
+		if o.Kind != ObstacleDriftwood {
+			continue
+		}
+		o.X16, o.VX16 = driftwoodStep(o.X16, o.VX16)
+	}
+}
+
+func driftwoodStep(x16, vx16 int) (int, int) {
+	x16 += vx16
+	if x16 < 0 {
+		return -x16, -vx16
+	}
+	if max16 := (ScreenWidth - DriftwoodWidth) * 16; x16 > max16 {
+		return 2*max16 - x16, -vx16
+	}
+	return x16, vx16
+}
+
+// whirlpoolPull returns the change of vx16 by the whirlpools the player is in
+// when the camera is at cameraY.
+func (w *World) whirlpoolPull(x16, cameraY int) int {
+	px := x16/16 + PlayerWidth/2
+	py := w.Y16/16 - w.CameraY + PlayerHeight/2
+	pull := 0
+	for _, o := range w.Obstacles {
+		if o.Kind != ObstacleWhirlpool {
+			continue
+		}
+		dx := o.X16/16 + WhirlpoolSize/2 - px
+		dy := o.Y + cameraY + WhirlpoolSize/2 - py
+		if dx*dx+dy*dy > WhirlpoolRadius*WhirlpoolRadius {
+			continue
+		}
+		switch {
+		case dx > 0:
+			pull += WhirlpoolPull
+		case dx < 0:
+			pull -= WhirlpoolPull
+		}
+	}
+	return pull
+}
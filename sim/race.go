@@ -0,0 +1,60 @@
+package sim
+
+import "cmp"
+
+// Race is a race of players on the same device. Every player has their own
+// World from the same config, so they meet the same waves, surfs, obstacles
+// and items however they move.
+type Race struct {
+	Worlds []*World
+}
+
+// NewRace creates a race of the players.
+func NewRace(c Config, players int) *Race {
+	r := &Race{}
+	for range players {
+		r.Worlds = append(r.Worlds, New(c))
+	}
+	return r
+}
+
+// Done reports whether every player has sunk or reached the goal.
+func (r *Race) Done() bool {
+	for _, w := range r.Worlds {
+		if !w.Over && !w.Goal {
+			return false
+		}
+	}
+	return true
+}
+
+// compareResults compares how well the players of a and b have done, and is
+// negative when a is better. Reaching the goal earlier is better, and so is
+// going farther.
+func compareResults(a, b *World) int {
+	if a.Goal != b.Goal {
+		if a.Goal {
+			return -1
+		}
+		return 1
+	}
+	if a.Goal {
+		return cmp.Compare(a.Frame, b.Frame)
+	}
+	return cmp.Compare(b.Distance(), a.Distance())
+}
+
+// Ranks returns the rank of each player from 1. Players who have done
+// equally well share the rank.
+func (r *Race) Ranks() []int {
+	ranks := make([]int, len(r.Worlds))
+	for i, a := range r.Worlds {
+		ranks[i] = 1
+		for _, b := range r.Worlds {
+			if compareResults(b, a) < 0 {
+				ranks[i]++
+			}
+		}
+	}
+	return ranks
+}
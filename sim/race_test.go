@@ -0,0 +1,58 @@
+package sim
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestRaceSharesLayout(t *testing.T) {
+	r := NewRace(Config{Seed: 1}, 2)
+	a, b := r.Worlds[0], r.Worlds[1]
+	// The first player boosts and strokes, and the second doesn't.
+	a.Boost = BoostFrames
+	a.Invincible, b.Invincible = true, true
+	surfs := map[int]Surf{}
+	for b.Distance() < 20000 {
+		in := Input{}
+		if a.Frame%30 == 0 {
+			in.Left = true
+		}
+		a.Step(in)
+		b.Step(Input{})
+		for _, s := range a.Surfs {
+			surfs[s.Y] = *s
+		}
+	}
+	checked := 0
+	for _, s := range b.Surfs {
+		if as, ok := surfs[s.Y]; ok {
+			if as != *s {
+				t.Fatalf("surf at %d: %+v and %+v", s.Y, as, *s)
+			}
+			checked++
+		}
+	}
+	if checked == 0 {
+		t.Fatal("no surfs to compare")
+	}
+}
+
+func TestRaceRanks(t *testing.T) {
+	r := NewRace(Config{Seed: 1}, 3)
+	for i, w := range r.Worlds {
+		w.Y16 += i * 16 * 100
+		w.Over = true
+	}
+	if got, want := r.Ranks(), []int{3, 2, 1}; !slices.Equal(got, want) {
+		t.Errorf("ranks %v, want %v", got, want)
+	}
+
+	r.Worlds[0].Over, r.Worlds[0].Goal = false, true
+	r.Worlds[1].Y16 = r.Worlds[2].Y16
+	if got, want := r.Ranks(), []int{1, 2, 2}; !slices.Equal(got, want) {
+		t.Errorf("ranks %v, want %v", got, want)
+	}
+	if !r.Done() {
+		t.Error("race is not done")
+	}
+}
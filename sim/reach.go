@@ -0,0 +1,153 @@
+package sim
+
+// tapInterval is the frames between the strokes of the player the surfs are
+// generated for. Faster players can do better, but the surfs never need it.
+const tapInterval = 8
+
+// reach returns how far in pixels the player can move sideways in the frames,
+// stroking every tapInterval frames while a wave pushes back all the time,
+// and a whirlpool too when pull is set. It follows the movement of Step. The
+// drag on the calm sea slows the player down no more than a wave pushing
+// back, so the reach holds there too.
+func (w *World) reach(frames int, pull bool) int {
+	back := w.WavePush
+	if pull {
+		back += WhirlpoolPull
+	}
+	x16, vx16, best := 0, 0, 0
+	for f := 0; f < frames; f++ {
+		if f%tapInterval == 0 {
+			vx16 = w.MaxVX16
+		}
+		x16 += vx16
+		vx16 = max(vx16-back, -w.MaxVX16)
+		best = max(best, x16)
+	}
+	return best / 16
+}
+
+// limit returns how far the gaps can be apart sideways for the player to go
+// through dist pixels of the sea between them at speed, which is the reach
+// scaled by the swing of the stage.
+func (w *World) limit(dist, speed int, s Stage, pull bool) int {
+	frames := max(dist, 0) / speed
+	return int(s.Swing * float64(w.reach(frames, pull)))
+}
+
+// worstSpeed returns the speed the player can go through the sea above the
+// surf prev of the stage at. The boost picked up below may end on the way,
+// and then the player has had less time to steer than at the speed of the
+// stage. The stages get no slower, so the boost goes no farther than at the
+// speed of this one.
+func (w *World) worstSpeed(prev *Surf, s Stage) int {
+	if b := w.lastBoost; b != nil && b.Y-prev.Y < BoostFrames*BoostSpeed*s.Speed+ItemSize+PlayerHeight {
+		return s.Speed * BoostSpeed
+	}
+	return s.Speed
+}
+
+// passage returns the range of the player's left edge that goes through the
+// gap of a surf with leftWidth and gap. The end of the left part of the surf
+// covers the first tile of the gap.
+func passage(leftWidth, gap int) (x0, x1 int) {
+	return (leftWidth + 1) * TileSize, (leftWidth+gap)*TileSize - PlayerWidth
+}
+
+// shift returns how far the player has to move sideways from the gap a to the
+// gap b.
+func shift(a0, a1, b0, b1 int) int {
+	switch {
+	case b0 > a1:
+		return b0 - a1
+	case a0 > b1:
+		return a0 - b1
+	}
+	return 0
+}
+
+// addSurf adds a surf of the stage at y, above prev. prev is nil for the
+// first surf.
+func (w *World) addSurf(prev *Surf, y int, s Stage) {
+	if prev == nil {
+		w.Surfs = append(w.Surfs, &Surf{
+			Y:         y,
+			LeftWidth: w.genSurfLeftWidth(nil, 0, s, false),
+			Gap:       s.SurfGap,
+		})
+		return
+	}
+	// The obstacle is picked first, as a whirlpool between the surfs pulls
+	// the player on the way.
+	kind, ok := s.Obstacles.pick(w.rng)
+	l := w.genSurfLeftWidth(prev, prev.Y-y, s, ok && kind == ObstacleWhirlpool)
+	next := &Surf{
+		Y:         y,
+		LeftWidth: l,
+		Gap:       s.SurfGap,
+		Shift:     l - prev.LeftWidth,
+	}
+	w.Surfs = append(w.Surfs, next)
+	if ok {
+		w.addObstacle(prev, next, s, kind)
+	}
+	w.addItem(prev, next, s)
+}
+
+// genSurfLeftWidth picks the position of the gap of the surf placed spacing
+// pixels above prev, from the ones that the player can reach through the
+// waves, and the whirlpool between them when pull is set. The swing of the
+// stage is how much of the reach the gaps can use. prev is nil for the first
+// surf.
+func (w *World) genSurfLeftWidth(prev *Surf, spacing int, s Stage, pull bool) int {
+	maxLeftWidth := ScreenWidth/TileSize - s.SurfGap - 1
+	if prev == nil {
+		return w.rng.IntN(maxLeftWidth) + 1
+	}
+
+	// The player can move sideways from leaving prev to entering the next.
+	limit := w.limit(spacing-SurfHeight-PlayerHeight, w.worstSpeed(prev, s), s, pull)
+
+	a0, a1 := passage(prev.LeftWidth, prev.Gap)
+	var candidates []int
+	for l := 1; l <= maxLeftWidth; l++ {
+		b0, b1 := passage(l, s.SurfGap)
+		if shift(a0, a1, b0, b1) <= limit {
+			candidates = append(candidates, l)
+		}
+	}
+	if len(candidates) == 0 {
+		// The gap closest to prev is always reachable at the same time.
+		return min(prev.LeftWidth, maxLeftWidth)
+	}
+	return candidates[w.rng.IntN(len(candidates))]
+}
+
+// rockClearance is the room the player needs between a rock and the way
+// around it, and the least width of the way, to pass it while the waves
+// push.
+const rockClearance = PlayerWidth / 2
+
+// aroundRock reports whether the player can go from the gap of prev to the
+// gap of next, passing beside the rock o between them.
+func (w *World) aroundRock(prev, next *Surf, o *Obstacle, s Stage) bool {
+	// How far the player can move sideways before the rock and after it.
+	speed := w.worstSpeed(prev, s)
+	l1 := w.limit(prev.Y-o.Y-RockSize-PlayerHeight, speed, s, false)
+	l2 := w.limit(o.Y-next.Y-SurfHeight-PlayerHeight, speed, s, false)
+
+	a0, a1 := passage(prev.LeftWidth, prev.Gap)
+	b0, b1 := passage(next.LeftWidth, next.Gap)
+	x := o.X16 / 16
+	// The player's left edge on the left of the rock, and on the right.
+	for _, side := range [][2]int{
+		{1, x - PlayerWidth - rockClearance},
+		{x + RockSize + rockClearance, ScreenWidth - PlayerWidth - 1},
+	} {
+		x0 := max(side[0], a0-l1, b0-l2)
+		x1 := min(side[1], a1+l1, b1+l2)
+		if x1-x0 >= rockClearance {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,177 @@
+package sim
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSurfsAreReachable(t *testing.T) {
+	for _, d := range Difficulties {
+		w := New(Config{Seed: 3, Difficulty: d, Endless: true})
+		w.Invincible = true
+		prev := w.Surfs[len(w.Surfs)-1]
+		checked := 0
+		for w.Frame < 60*60*10 {
+			w.Step(Input{})
+			next := w.Surfs[len(w.Surfs)-1]
+			if next == prev {
+				continue
+			}
+			s := w.stageAtSurf(prev.Y)
+			frames := max(prev.Y-next.Y-SurfHeight-PlayerHeight, 0) / s.Speed
+			a0, a1 := passage(prev.LeftWidth, prev.Gap)
+			b0, b1 := passage(next.LeftWidth, next.Gap)
+			if got, limit := shift(a0, a1, b0, b1), w.reach(frames, false); got > limit {
+				t.Fatalf("%s: surf at %d needs %dpx in %d frames, can move %dpx", d, next.Y, got, frames, limit)
+			}
+			prev = next
+			checked++
+		}
+		if checked < 100 {
+			t.Fatalf("%s: only %d surfs checked", d, checked)
+		}
+	}
+}
+
+func TestReachAgainstWaves(t *testing.T) {
+	w := New(Config{Seed: 1})
+	calm := New(Config{Seed: 1, Difficulty: DifficultyEasy})
+	if w.reach(0, false) != 0 {
+		t.Errorf("reach(0) = %d", w.reach(0, false))
+	}
+	if w.reach(60, false) >= calm.reach(60, false) {
+		t.Errorf("stronger waves reach %dpx, weaker %dpx", w.reach(60, false), calm.reach(60, false))
+	}
+	if w.reach(60, true) >= w.reach(60, false) {
+		t.Errorf("reach with a whirlpool %dpx, without %dpx", w.reach(60, true), w.reach(60, false))
+	}
+}
+
+func TestSurfsAreReachableWithObstacles(t *testing.T) {
+	stages := DefaultStages()
+	for i := range stages {
+		stages[i].Obstacles = ObstacleWeights{Rock: 1, Whirlpool: 1}
+	}
+	for _, d := range Difficulties {
+		w := New(Config{Seed: 5, Stages: stages, Difficulty: d, Endless: true})
+		w.Invincible = true
+		prev := w.Surfs[len(w.Surfs)-1]
+		rocks, whirlpools := 0, 0
+		for w.Frame < 60*60*5 {
+			w.Step(Input{})
+			next := w.Surfs[len(w.Surfs)-1]
+			if next == prev {
+				continue
+			}
+			s := w.stageAtSurf(prev.Y)
+			a0, a1 := passage(prev.LeftWidth, prev.Gap)
+			b0, b1 := passage(next.LeftWidth, next.Gap)
+			leg := func(dist int, pull bool) int {
+				return w.reach(max(dist, 0)/s.Speed, pull)
+			}
+
+			var o *Obstacle
+			if n := len(w.Obstacles); n > 0 && w.Obstacles[n-1].Y < prev.Y && w.Obstacles[n-1].Y > next.Y {
+				o = w.Obstacles[n-1]
+			}
+			switch {
+			case o == nil:
+			case o.Kind == ObstacleWhirlpool:
+				whirlpools++
+				if got, limit := shift(a0, a1, b0, b1), leg(prev.Y-next.Y-SurfHeight-PlayerHeight, true); got > limit {
+					t.Fatalf("%s: surf at %d needs %dpx through a whirlpool, can move %dpx", d, next.Y, got, limit)
+				}
+			case o.Kind == ObstacleRock:
+				rocks++
+				// Some place beside the rock can be reached from prev, and
+				// next from there.
+				l1 := leg(prev.Y-o.Y-RockSize-PlayerHeight, false)
+				l2 := leg(o.Y-next.Y-SurfHeight-PlayerHeight, false)
+				rx := o.X16 / 16
+				found := false
+				for x := 1; x < ScreenWidth-PlayerWidth && !found; x++ {
+					if x+PlayerWidth > rx && x < rx+RockSize {
+						continue
+					}
+					found = shift(a0, a1, x, x) <= l1 && shift(x, x, b0, b1) <= l2
+				}
+				if !found {
+					t.Fatalf("%s: no way around the rock at (%d, %d)", d, rx, o.Y)
+				}
+			}
+			prev = next
+		}
+		if rocks < 10 || whirlpools < 10 {
+			t.Fatalf("%s: only %d rocks and %d whirlpools checked", d, rocks, whirlpools)
+		}
+	}
+}
+
+func TestSurfsAreReachableAfterBoost(t *testing.T) {
+	stages := DefaultStages()
+	for i := range stages {
+		stages[i].Obstacles = ObstacleWeights{None: 1}
+		stages[i].Items = ItemWeights{None: 3, Boost: 1}
+	}
+	for _, d := range Difficulties {
+		w := New(Config{Seed: 7, Stages: stages, Difficulty: d, Endless: true})
+		w.Invincible = true
+		prev := w.Surfs[len(w.Surfs)-1]
+		var boosts []*Item
+		checked := 0
+		for w.Frame < 60*60*5 {
+			w.Step(Input{})
+			for _, it := range w.Items {
+				if it.Kind == ItemBoost && !slices.Contains(boosts, it) {
+					boosts = append(boosts, it)
+				}
+			}
+			next := w.Surfs[len(w.Surfs)-1]
+			if next == prev {
+				continue
+			}
+			s := w.stageAtSurf(prev.Y)
+			// A boost picked up below prev may end anywhere on the way to
+			// the next, after going through the sea at double speed.
+			boosted := false
+			for _, it := range boosts {
+				if it.Y > prev.Y && it.Y-prev.Y < BoostFrames*BoostSpeed*s.Speed {
+					boosted = true
+				}
+			}
+			if !boosted {
+				prev = next
+				continue
+			}
+			frames := max(prev.Y-next.Y-SurfHeight-PlayerHeight, 0) / (s.Speed * BoostSpeed)
+			a0, a1 := passage(prev.LeftWidth, prev.Gap)
+			b0, b1 := passage(next.LeftWidth, next.Gap)
+			if got, limit := shift(a0, a1, b0, b1), w.reach(frames, false); got > limit {
+				t.Fatalf("%s: surf at %d after a boost needs %dpx in %d frames, can move %dpx", d, next.Y, got, frames, limit)
+			}
+			prev = next
+			checked++
+		}
+		if checked < 20 {
+			t.Fatalf("%s: only %d surfs after a boost checked", d, checked)
+		}
+	}
+}
+
+func TestReachOnCalmSea(t *testing.T) {
+	const frames = 40
+	for _, d := range Difficulties {
+		w := New(Config{Seed: 1, Difficulty: d})
+		w.Invincible = true
+		w.Calm = CalmFrames
+		// From the left, to leave room on the right.
+		w.X16 = TileSize * 16
+		x16 := w.X16
+		for f := 0; f < frames; f++ {
+			w.Step(Input{Right: f%tapInterval == 0})
+		}
+		if got, want := (w.X16-x16)/16, w.reach(frames, false); got < want {
+			t.Errorf("%s: moved %dpx on the calm sea, reach is %dpx", d, got, want)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package sim
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// replayMagic is the first bytes of a replay file.
+const replayMagic = "SNKR"
+
+// replayVersion is bumped when the format changes, and also when the waves,
+// surfs, obstacles or items are generated or move differently, as the inputs
+// of a replay only play the same run back in the same world. Replays of older
+// versions are rejected.
+//
+// 2 added the difficulty, 3 added the flags of the mode and 4 added the start
+// stage. 5 is for the gaps that can be reached through the obstacles and
+// after the boost, and 6 for the hits of the tilted player in fixed point. 7
+// added the route.
+const replayVersion = 7
+
+// The flags of the mode in replays.
+const (
+	replayEndless = 1 << 0
+)
+
+// maxReplayFrames is an hour at 60 TPS, far longer than any real run.
+const maxReplayFrames = 60 * 60 * 60
+
+// maxReplayStartStage is far more than the stages of any route.
+const maxReplayStartStage = 1000
+
+var ErrInvalidReplay = errors.New("sim: invalid replay")
+
+// Replay is the record of a run: the config and the input of every frame.
+type Replay struct {
+	// Route is the RouteHash of the game the run was played in.
+	Route      uint64
+	Seed       uint64
+	Difficulty Difficulty
+	Endless    bool
+	StartStage int
+	Inputs     []Input
+}
+
+// Config returns the config to replay the run with.
+func (r *Replay) Config() Config {
+	return Config{
+		Seed:       r.Seed,
+		Difficulty: r.Difficulty,
+		Endless:    r.Endless,
+		StartStage: r.StartStage,
+	}
+}
+
+// Record appends the input of one frame.
+func (r *Replay) Record(in Input) {
+	r.Inputs = append(r.Inputs, in)
+}
+
+// Input returns the input recorded for the frame, and false when the
+// recording has ended.
+func (r *Replay) Input(frame int) (Input, bool) {
+	if frame < 0 || frame >= len(r.Inputs) {
+		return Input{}, false
+	}
+	return r.Inputs[frame], true
+}
+
+func (in Input) bits() byte {
+	var b byte
+	if in.Left {
+		b |= 1 << 0
+	}
+	if in.Right {
+		b |= 1 << 1
+	}
+	if in.Select {
+		b |= 1 << 2
+	}
+	return b
+}
+
+func inputFromBits(b byte) Input {
+	return Input{
+		Left:   b&(1<<0) != 0,
+		Right:  b&(1<<1) != 0,
+		Select: b&(1<<2) != 0,
+	}
+}
+
+// MarshalBinary encodes the replay. Most frames have no input, so the inputs
+// are stored as runs of the same input.
+//
+//	magic "SNKR" | version (1 byte) | route (8 bytes, big endian) |
+//	seed (8 bytes, big endian) |
+//	difficulty (1 byte) | flags (1 byte) | start stage (uvarint) |
+//	frames (uvarint) | { input bits (1 byte) | run length (uvarint) }...
+func (r *Replay) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(replayMagic)
+	buf.WriteByte(replayVersion)
+	buf.Write(binary.BigEndian.AppendUint64(nil, r.Route))
+	buf.Write(binary.BigEndian.AppendUint64(nil, r.Seed))
+	buf.WriteByte(byte(r.Difficulty))
+	var flags byte
+	if r.Endless {
+		flags |= replayEndless
+	}
+	buf.WriteByte(flags)
+	buf.Write(binary.AppendUvarint(nil, uint64(r.StartStage)))
+	buf.Write(binary.AppendUvarint(nil, uint64(len(r.Inputs))))
+
+	for i := 0; i < len(r.Inputs); {
+		b := r.Inputs[i].bits()
+		n := 1
+		for i+n < len(r.Inputs) && r.Inputs[i+n].bits() == b {
+			n++
+		}
+		buf.WriteByte(b)
+		buf.Write(binary.AppendUvarint(nil, uint64(n)))
+		i += n
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary decodes a replay encoded by MarshalBinary.
+func (r *Replay) UnmarshalBinary(data []byte) error {
+	br := bytes.NewReader(data)
+
+	magic := make([]byte, len(replayMagic))
+	if _, err := io.ReadFull(br, magic); err != nil || string(magic) != replayMagic {
+		return fmt.Errorf("%w: not a replay file", ErrInvalidReplay)
+	}
+	v, err := br.ReadByte()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidReplay, err)
+	}
+	if v < replayVersion {
+		return fmt.Errorf("%w: version %d is of an older game", ErrInvalidReplay, v)
+	}
+	if v > replayVersion {
+		return fmt.Errorf("%w: unsupported version %d", ErrInvalidReplay, v)
+	}
+
+	var route, seed [8]byte
+	if _, err := io.ReadFull(br, route[:]); err != nil {
+		return fmt.Errorf("%w: route: %v", ErrInvalidReplay, err)
+	}
+	if _, err := io.ReadFull(br, seed[:]); err != nil {
+		return fmt.Errorf("%w: seed: %v", ErrInvalidReplay, err)
+	}
+	b, err := br.ReadByte()
+	if err != nil {
+		return fmt.Errorf("%w: difficulty: %v", ErrInvalidReplay, err)
+	}
+	difficulty := Difficulty(b)
+	if !difficulty.Valid() {
+		return fmt.Errorf("%w: unknown difficulty %d", ErrInvalidReplay, b)
+	}
+	flags, err := br.ReadByte()
+	if err != nil {
+		return fmt.Errorf("%w: flags: %v", ErrInvalidReplay, err)
+	}
+	if flags&^replayEndless != 0 {
+		return fmt.Errorf("%w: unknown flags %#x", ErrInvalidReplay, flags)
+	}
+	start, err := binary.ReadUvarint(br)
+	if err != nil {
+		return fmt.Errorf("%w: start stage: %v", ErrInvalidReplay, err)
+	}
+	if start > maxReplayStartStage {
+		return fmt.Errorf("%w: start stage %d", ErrInvalidReplay, start)
+	}
+	frames, err := binary.ReadUvarint(br)
+	if err != nil {
+		return fmt.Errorf("%w: frames: %v", ErrInvalidReplay, err)
+	}
+	if frames > maxReplayFrames {
+		return fmt.Errorf("%w: too many frames", ErrInvalidReplay)
+	}
+
+	inputs := make([]Input, 0, frames)
+	for uint64(len(inputs)) < frames {
+		b, err := br.ReadByte()
+		if err != nil {
+			return fmt.Errorf("%w: inputs: %v", ErrInvalidReplay, err)
+		}
+		n, err := binary.ReadUvarint(br)
+		if err != nil {
+			return fmt.Errorf("%w: inputs: %v", ErrInvalidReplay, err)
+		}
+		if n == 0 || n > frames-uint64(len(inputs)) {
+			return fmt.Errorf("%w: broken run length", ErrInvalidReplay)
+		}
+		in := inputFromBits(b)
+		for ; n > 0; n-- {
+			inputs = append(inputs, in)
+		}
+	}
+	if br.Len() != 0 {
+		return fmt.Errorf("%w: trailing data", ErrInvalidReplay)
+	}
+
+	r.Route = binary.BigEndian.Uint64(route[:])
+	r.Seed = binary.BigEndian.Uint64(seed[:])
+	r.Difficulty = difficulty
+	r.Endless = flags&replayEndless != 0
+	r.StartStage = int(start)
+	r.Inputs = inputs
+	return nil
+}
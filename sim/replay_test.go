@@ -0,0 +1,83 @@
+package sim
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestReplayRoundTrip(t *testing.T) {
+	w := New(Config{Seed: 42, Difficulty: DifficultyHard, Endless: true, StartStage: 2})
+	r := &Replay{Route: RouteHash(), Seed: w.Seed, Difficulty: w.Difficulty, Endless: w.Endless, StartStage: w.StartStage}
+	for !w.Over {
+		in := Input{}
+		if w.Frame%8 == 0 {
+			in = steer(w)
+		}
+		in.Select = in.Left || in.Right
+		r.Record(in)
+		w.Step(in)
+	}
+
+	b, err := r.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b) > len(r.Inputs) {
+		t.Errorf("replay is not compact: %d bytes for %d frames", len(b), len(r.Inputs))
+	}
+
+	var got Replay
+	if err := got.UnmarshalBinary(b); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(r, &got) {
+		t.Fatal("decoded replay differs")
+	}
+
+	// Playing the replay back reaches the same end.
+	p := New(got.Config())
+	for !p.Over {
+		in, ok := got.Input(p.Frame)
+		if !ok {
+			t.Fatal("replay ended before the run was over")
+		}
+		p.Step(in)
+	}
+	if p.Frame != w.Frame || p.Distance() != w.Distance() {
+		t.Fatalf("replay ended at frame %d (%dm), want frame %d (%dm)", p.Frame, p.Distance(), w.Frame, w.Distance())
+	}
+}
+
+func TestReplayVersion(t *testing.T) {
+	var r Replay
+	if err := r.UnmarshalBinary([]byte("SNKR\x07\x01\x02\x03\x04\x05\x06\x07\x08\x00\x00\x00\x00\x00\x00\x00\x2a\x02\x01\x03\x03\x00\x02\x01\x01")); err != nil {
+		t.Fatal(err)
+	}
+	want := Replay{Route: 0x0102030405060708, Seed: 42, Difficulty: DifficultyHard, Endless: true, StartStage: 3, Inputs: []Input{{}, {}, {Left: true}}}
+	if !reflect.DeepEqual(r, want) {
+		t.Fatalf("got %+v, want %+v", r, want)
+	}
+
+	// Replays of older versions were recorded in other worlds.
+	if err := r.UnmarshalBinary([]byte("SNKR\x06\x00\x00\x00\x00\x00\x00\x00\x2a\x01\x00\x00\x03\x00\x02\x01\x01")); !errors.Is(err, ErrInvalidReplay) {
+		t.Errorf("version 6: got %v, want ErrInvalidReplay", err)
+	}
+}
+
+func TestReplayInvalid(t *testing.T) {
+	for _, b := range [][]byte{
+		nil,
+		[]byte("nope"),
+		[]byte("SNKR\x09"),
+		[]byte("SNKR\x07\x01\x02"),
+		[]byte("SNKR\x07\x01\x02\x03\x04\x05\x06\x07\x08\x00\x00\x00\x00\x00\x00\x00\x01\x01\x00\x00\x05\x00\x02"),
+		[]byte("SNKR\x07\x01\x02\x03\x04\x05\x06\x07\x08\x00\x00\x00\x00\x00\x00\x00\x01\x09\x00\x00\x00"),
+		[]byte("SNKR\x07\x01\x02\x03\x04\x05\x06\x07\x08\x00\x00\x00\x00\x00\x00\x00\x01\x01\x02\x00\x00"),
+	} {
+		var r Replay
+		if err := r.UnmarshalBinary(b); !errors.Is(err, ErrInvalidReplay) {
+			t.Errorf("%q: got %v, want ErrInvalidReplay", b, err)
+		}
+	}
+}
@@ -0,0 +1,448 @@
+// Package sim is the game simulation of 島抜けチュータ.
+//
+// It has no dependency on Ebiten, so a run can be stepped frame by frame
+// without any graphics context, e.g. from tests or command line tools.
+package sim
+
+import (
+	"math"
+	"math/rand/v2"
+	"slices"
+)
+
+const (
+	ScreenWidth  = 480
+	ScreenHeight = 640
+
+	WaveAreaWidth  = ScreenWidth
+	WaveAreaHeight = ScreenHeight
+
+	TileSize = 32
+
+	PlayerWidth     = 64
+	PlayerHeight    = 64
+	PlayerPositionY = (ScreenHeight - PlayerHeight - TileSize*4) * 16
+
+	SurfWidth  = 64
+	SurfHeight = 64
+
+	SurfStartOffset = 48
+
+	// MaxVX16 is the player's horizontal speed right after a stroke, and
+	// WavePush is how much a wave changes it every frame, in normal difficulty.
+	MaxVX16  = 96
+	WavePush = 4
+)
+
+// TravelDistance returns the travelled distance in meters for the player's y16.
+func TravelDistance(y16 int) int {
+	return (y16 - PlayerPositionY) / 16 * 20
+}
+
+// PxToTravelDistance converts pixels to meters.
+func PxToTravelDistance(y int) int {
+	return y * 20
+}
+
+type WaveType int
+
+const (
+	WaveToLeft WaveType = iota
+	WaveToRight
+)
+
+type WaveArea struct {
+	Y        int
+	WaveType WaveType
+}
+
+type Surf struct {
+	Y         int
+	LeftWidth int
+	Gap       int
+
+	// Shift is how far the gap has moved from the previous surf, in tiles.
+	Shift int
+}
+
+type Stage struct {
+	Name         string `json:"name"`
+	Dist         int    `json:"dist"`
+	Speed        int    `json:"speed"`
+	SurfGap      int    `json:"surfGap"`
+	SurfInterval int    `json:"surfInterval"`
+	// Swing is how far apart consecutive gaps can be, as the ratio to the
+	// distance the player can move between them, in (0, 1].
+	Swing float64 `json:"swing"`
+	// Obstacles are the chances of the obstacles between surfs.
+	Obstacles ObstacleWeights `json:"obstacles"`
+	// Items are the chances of the power-ups between surfs.
+	Items ItemWeights `json:"items"`
+}
+
+type Stages []Stage
+
+// Input is the player's input for one frame.
+type Input struct {
+	Left  bool
+	Right bool
+
+	// Select is not used by the world, but is recorded in replays.
+	Select bool
+}
+
+// Config is how a run is set up.
+type Config struct {
+	Seed uint64
+	// Stages is the route before the difficulty is applied. DefaultStages
+	// is used when it is nil.
+	Stages     Stages
+	Difficulty Difficulty
+	// Endless makes the route go on past the last stage with the waypoints of
+	// DefaultEndless, instead of ending the run there.
+	Endless bool
+	// StartStage is the index of the stage the run starts from. Out of range
+	// values are clamped, and a run that is not endless can't start from the
+	// last stage, where it would end at once.
+	StartStage int
+}
+
+// World is the whole state of a run.
+type World struct {
+	Seed       uint64
+	Stages     Stages
+	Difficulty Difficulty
+	Endless    bool
+	StartStage int
+
+	// GoalDist is the dist of the last stage of the route in km. Reaching it
+	// ends the run unless the world is endless.
+	GoalDist int
+
+	// The player's movement, scaled by the difficulty.
+	MaxVX16  int
+	WavePush int
+
+	// Frame is the number of steps since the run started.
+	Frame int
+
+	Speed        int
+	SurfInterval int
+	SurfGap      int
+	Location     string
+
+	// Camera
+	CameraY int
+
+	// The player's position
+	X16  int
+	Y16  int
+	VX16 int
+
+	ShipDir         int
+	CountAfterClick int
+
+	WaveAreas []*WaveArea
+	Surfs     []*Surf
+	Obstacles []*Obstacle
+	Items     []*Item
+
+	// Picked is the items picked up in the last step.
+	Picked []*Item
+	// Shield is set while the player has a shield. Calm, Boost and Recover
+	// are the frames left of the power-ups.
+	Shield  bool
+	Calm    int
+	Boost   int
+	Recover int
+
+	// Invincible disables the game over on hit.
+	Invincible bool
+
+	// Over is set when the player has hit something.
+	Over bool
+	// Killer or KillerObstacle is what the player has hit. Both are nil
+	// when the player has hit the edge of the screen.
+	Killer         *Surf
+	KillerObstacle *Obstacle
+	// Goal is set when the player has reached the last stage.
+	Goal bool
+
+	rng *rand.Rand
+
+	// The endless mode
+	profile   Profile
+	endless   Endless
+	lastStage Stage
+	waypoints int
+
+	// lastBoost is the last boost item generated, which may narrow the reach
+	// of the gaps above it.
+	lastBoost *Item
+}
+
+// New creates a world for a run. The same config always produces the same
+// waves and surfs for the same inputs.
+func New(c Config) *World {
+	stages := c.Stages
+	if stages == nil {
+		stages = DefaultStages()
+	}
+	p := c.Difficulty.Profile()
+	last := len(stages) - 1
+	if !c.Endless {
+		last = max(last-1, 0)
+	}
+	start := min(max(c.StartStage, 0), last)
+	// The camera is at the start stage, as if the player had come all
+	// the way from the first one.
+	cameraY := stages[start].Dist * 1000 / PxToTravelDistance(1)
+	w := &World{
+		Seed:       c.Seed,
+		Stages:     stages.Scaled(p),
+		Difficulty: c.Difficulty,
+		Endless:    c.Endless,
+		StartStage: start,
+		GoalDist:   stages[len(stages)-1].Dist,
+		MaxVX16:    int(math.Round(MaxVX16 * p.MaxVX)),
+		WavePush:   int(math.Round(WavePush * p.WavePush)),
+		CameraY:    cameraY,
+		X16:        (ScreenWidth/2 - PlayerWidth/2) * 16,
+		Y16:        PlayerPositionY + cameraY*16,
+		rng:        rand.New(rand.NewPCG(c.Seed, c.Seed)),
+		profile:    p,
+		endless:    DefaultEndless(),
+		lastStage:  stages[len(stages)-1],
+	}
+	w.setStage()
+
+	//init waves
+	waveY := -cameraY / ScreenHeight * ScreenHeight
+	for i := 0; i < 3; i++ {
+		t := WaveToLeft
+		if i%2 == 0 {
+			t = WaveToRight
+		}
+		w.WaveAreas = append(w.WaveAreas, &WaveArea{
+			Y:        waveY - WaveAreaHeight*i,
+			WaveType: t,
+		})
+	}
+
+	//init surfs
+	initSurfsNum := int(math.Floor(float64(ScreenHeight / ((w.SurfGap + 1) * SurfHeight))))
+	if initSurfsNum < 1 {
+		initSurfsNum = 1
+	}
+	for i := 0; i < initSurfsNum; i++ {
+		s := w.Stages[start]
+		y := -cameraY - SurfStartOffset*TileSize
+		var prev *Surf
+
+		if i > 0 {
+			prev = w.Surfs[len(w.Surfs)-1]
+			s = w.stageAtSurf(prev.Y)
+			y = prev.Y - SurfHeight - s.SurfGap*TileSize
+		}
+
+		w.addSurf(prev, y, s)
+	}
+	return w
+}
+
+// Step advances the world by one frame.
+func (w *World) Step(in Input) {
+	if w.Over || w.Goal {
+		return
+	}
+	w.Frame++
+	w.Calm = max(w.Calm-1, 0)
+	w.Boost = max(w.Boost-1, 0)
+	w.Recover = max(w.Recover-1, 0)
+	w.setStage()
+
+	w.CountAfterClick += 1
+	w.CameraY += w.Speed
+	w.Y16 += w.Speed * 16
+
+	if in.Right {
+		w.ShipDir = 1
+		w.CountAfterClick = 0
+		w.VX16 = w.MaxVX16
+	}
+	if in.Left {
+		w.ShipDir = 2
+		w.CountAfterClick = 0
+		w.VX16 = -w.MaxVX16
+	}
+
+	w.X16 += w.VX16
+	//Check is player moves off screen
+	if w.X16 < 0 {
+		w.X16 = 0
+	}
+	if w.X16 > (ScreenWidth-PlayerWidth)*16 {
+		w.X16 = (ScreenWidth - PlayerWidth) * 16
+	}
+
+	w.VX16 += w.WaveDirection()
+	if w.Calm > 0 {
+		w.VX16 = w.calmDrag(w.VX16)
+	}
+	w.VX16 += w.whirlpoolPull(w.X16, w.CameraY)
+	w.moveObstacles()
+
+	if w.VX16 > w.MaxVX16 {
+		w.VX16 = w.MaxVX16
+	}
+	if w.VX16 < -w.MaxVX16 {
+		w.VX16 = -w.MaxVX16
+	}
+
+	//Add wave
+	if w.CameraY%ScreenHeight < w.Speed {
+		t := WaveToLeft
+		if w.rng.IntN(2)%2 == 0 {
+			t = WaveToRight
+		}
+		w.WaveAreas = append(w.WaveAreas, &WaveArea{
+			Y:        w.WaveAreas[len(w.WaveAreas)-1].Y - ScreenHeight,
+			WaveType: t,
+		})
+		w.WaveAreas = w.WaveAreas[1:]
+	}
+
+	//Add surfs
+	if w.CameraY%((w.SurfInterval+1)*TileSize) < w.Speed {
+		prev := w.Surfs[len(w.Surfs)-1]
+		s := w.stageAtSurf(prev.Y)
+		spacing := SurfHeight + s.SurfInterval*TileSize
+		w.addSurf(prev, prev.Y-spacing, s)
+
+		rmCount := 0
+		for _, s := range w.Surfs {
+			if s.Y+w.CameraY > ScreenHeight {
+				rmCount++
+			}
+		}
+		w.Surfs = w.Surfs[rmCount:]
+
+		w.Obstacles = slices.DeleteFunc(w.Obstacles, func(o *Obstacle) bool {
+			return o.Y+w.CameraY > ScreenHeight
+		})
+		w.Items = slices.DeleteFunc(w.Items, func(it *Item) bool {
+			return it.Y+w.CameraY > ScreenHeight
+		})
+	}
+
+	w.pickItems()
+	if s, o, hit := w.hit(); hit && !w.Invincible && !w.protected(s, o) {
+		w.Over = true
+		w.Killer = s
+		w.KillerObstacle = o
+	}
+	if !w.Over && !w.Endless && w.Distance() >= w.GoalDist*1000 {
+		w.setStage()
+		w.Goal = true
+	}
+}
+
+// Distance returns the travelled distance in meters.
+func (w *World) Distance() int {
+	return TravelDistance(w.Y16)
+}
+
+func (w *World) setStage() {
+	w.extendStages(w.Distance())
+	var s Stage
+	for _, v := range w.Stages {
+		if v.Dist*1000 <= w.Distance() {
+			s = v
+		}
+	}
+	w.Speed = s.Speed
+	if w.Boost > 0 {
+		w.Speed *= BoostSpeed
+	}
+	w.SurfInterval = s.SurfInterval
+	w.SurfGap = s.SurfGap
+	w.Location = s.Name
+}
+
+// stageAtSurf returns the stage that a new surf placed after lastY belongs to.
+func (w *World) stageAtSurf(lastY int) Stage {
+	w.extendStages(PxToTravelDistance(-lastY))
+	s := w.Stages[0]
+	for _, v := range w.Stages {
+		if v.Dist*1000 < PxToTravelDistance(-lastY) {
+			s = v
+		}
+	}
+	return s
+}
+
+// Hit reports whether the player touches the edge of the screen, or a solid
+// pixel of the player's sprite overlaps a surf.
+func (w *World) Hit() bool {
+	_, _, hit := w.hit()
+	return hit
+}
+
+// hit is like Hit, and also returns the surf or the obstacle the player
+// touches.
+func (w *World) hit() (*Surf, *Obstacle, bool) {
+	x0 := int(math.Floor(float64(w.X16 / 16)))
+	x1 := x0 + PlayerWidth
+	y0 := int(math.Floor(float64(w.Y16/16))) - w.CameraY
+
+	//out of screen
+	if x0 <= 0 {
+		return nil, nil, true
+	}
+	if x1 >= ScreenWidth {
+		return nil, nil, true
+	}
+
+	//hit surf
+	for _, s := range w.Surfs {
+		if w.hitSurf(s, x0, y0) {
+			return s, nil, true
+		}
+	}
+	for _, o := range w.Obstacles {
+		if w.hitObstacle(o, x0, y0) {
+			return nil, o, true
+		}
+	}
+	return nil, nil, false
+}
+
+// WaveDirection returns the push of the wave the player is in. It is 0 while
+// the sea is calm.
+func (w *World) WaveDirection() int {
+	if w.Calm > 0 {
+		return 0
+	}
+	return w.waveDirectionAt(w.CameraY)
+}
+
+// waveDirectionAt returns the push of the wave the player would be in when
+// the camera is at cameraY. It is 0 beyond the wave areas generated so far.
+func (w *World) waveDirectionAt(cameraY int) int {
+	y := w.Y16/16 - w.CameraY
+	for _, a := range w.WaveAreas {
+		wy0 := a.Y + cameraY
+		wy1 := a.Y + cameraY + WaveAreaHeight
+		if y > wy0 && y <= wy1 {
+			switch a.WaveType {
+			case WaveToLeft:
+				return -w.WavePush
+			case WaveToRight:
+				return w.WavePush
+			default:
+				return 0
+			}
+		}
+	}
+	return 0
+}
@@ -0,0 +1,198 @@
+package sim
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+)
+
+// steer taps towards the middle of the next surf's gap.
+func steer(w *World) Input {
+	x := w.X16/16 + PlayerWidth/2
+	for _, s := range w.Surfs {
+		if s.Y+w.CameraY+SurfHeight > w.Y16/16-w.CameraY {
+			continue
+		}
+		center := (s.LeftWidth*2 + s.Gap) * TileSize / 2
+		return Input{Left: x > center+TileSize, Right: x < center-TileSize}
+	}
+	return Input{}
+}
+
+func run(seed uint64, frames int) *World {
+	w := New(Config{Seed: seed})
+	for i := 0; i < frames && !w.Over; i++ {
+		in := Input{}
+		if w.Frame%8 == 0 {
+			in = steer(w)
+		}
+		w.Step(in)
+	}
+	return w
+}
+
+func TestDeterministic(t *testing.T) {
+	for seed := uint64(0); seed < 20; seed++ {
+		a := run(seed, 3000)
+		b := run(seed, 3000)
+		a.rng, b.rng = nil, nil
+		if !reflect.DeepEqual(a, b) {
+			t.Fatalf("seed %d: two runs diverged", seed)
+		}
+	}
+}
+
+func TestSeedChangesLayout(t *testing.T) {
+	a := New(Config{Seed: 1})
+	b := New(Config{Seed: 2})
+	same := true
+	for i := range a.Surfs {
+		if a.Surfs[i].LeftWidth != b.Surfs[i].LeftWidth {
+			same = false
+		}
+	}
+	for i := 0; i < 2000; i++ {
+		a.Step(Input{})
+		b.Step(Input{})
+	}
+	for i := range a.WaveAreas {
+		if a.WaveAreas[i].WaveType != b.WaveAreas[i].WaveType {
+			same = false
+		}
+	}
+	if same {
+		t.Fatal("different seeds produced the same layout")
+	}
+}
+
+func TestInvincibleRunsToTokyo(t *testing.T) {
+	w := New(Config{Seed: 1})
+	w.Invincible = true
+	goal := w.Stages[len(w.Stages)-1]
+	for w.Location != goal.Name {
+		if w.Frame > 100000 {
+			t.Fatalf("did not reach %s, stopped at %s (%dm)", goal.Name, w.Location, w.Distance())
+		}
+		w.Step(Input{})
+	}
+	if w.Over {
+		t.Fatal("invincible world is over")
+	}
+	if w.Speed != goal.Speed || w.SurfGap != goal.SurfGap {
+		t.Fatalf("stage parameters not applied: speed %d gap %d", w.Speed, w.SurfGap)
+	}
+	if !w.Goal {
+		t.Fatal("reaching the last stage is not the goal")
+	}
+	frame := w.Frame
+	w.Step(Input{})
+	if w.Frame != frame {
+		t.Fatal("world moved after the goal")
+	}
+}
+
+func TestEndlessGoesPastTokyo(t *testing.T) {
+	w := New(Config{Seed: 1, Endless: true})
+	w.Invincible = true
+	last := w.Stages[len(w.Stages)-1]
+	for w.Distance() < (last.Dist+DefaultEndless().Interval*3)*1000 {
+		if w.Goal || w.Frame > 200000 {
+			t.Fatalf("stopped at %s (%dm)", w.Location, w.Distance())
+		}
+		w.Step(Input{})
+	}
+	if i := slices.IndexFunc(w.Stages, func(s Stage) bool { return s.Name == w.Location }); i < 0 || w.Stages[i].Dist <= last.Dist {
+		t.Fatalf("location %s is not a waypoint", w.Location)
+	}
+	if w.Speed <= last.Speed || w.SurfInterval >= last.SurfInterval {
+		t.Fatalf("waypoint is not harder: speed %d interval %d", w.Speed, w.SurfInterval)
+	}
+}
+
+func TestStartStage(t *testing.T) {
+	stages := DefaultStages()
+	w := New(Config{Seed: 1, StartStage: 6})
+	w.Invincible = true
+	if w.Location != stages[6].Name || w.Distance() != stages[6].Dist*1000 {
+		t.Fatalf("started at %s (%dm), want %s", w.Location, w.Distance(), stages[6].Name)
+	}
+	if y := w.Surfs[0].Y + w.CameraY; y != -SurfStartOffset*TileSize {
+		t.Errorf("first surf at %d on the screen, want %d", y, -SurfStartOffset*TileSize)
+	}
+	for w.Location != stages[7].Name {
+		if w.Frame > 100000 {
+			t.Fatalf("did not reach %s, stopped at %s (%dm)", stages[7].Name, w.Location, w.Distance())
+		}
+		w.Step(Input{})
+	}
+}
+
+func TestStartStageGoal(t *testing.T) {
+	stages := DefaultStages()
+	last := len(stages) - 1
+
+	// A practice run from the goal would end on the first frame.
+	w := New(Config{Seed: 1, StartStage: last})
+	if w.StartStage != last-1 {
+		t.Fatalf("started from stage %d, want %d", w.StartStage, last-1)
+	}
+	w.Step(Input{})
+	if w.Goal {
+		t.Fatal("reached the goal on the first frame")
+	}
+
+	w = New(Config{Seed: 1, Endless: true, StartStage: last})
+	if w.StartStage != last {
+		t.Errorf("endless run started from stage %d, want %d", w.StartStage, last)
+	}
+}
+
+func TestHitIgnoresTransparentPixels(t *testing.T) {
+	w := New(Config{Seed: 1})
+	py := w.Y16/16 - w.CameraY
+
+	// A surf whose right part starts at the middle of the screen, with its
+	// top at the middle of the player.
+	s := &Surf{LeftWidth: 1, Gap: ScreenWidth/TileSize/2 - 1}
+	s.Y = py + PlayerHeight/2 - w.CameraY
+	w.Surfs = []*Surf{s}
+	lx0 := (s.LeftWidth + s.Gap) * TileSize
+
+	transparent := false
+	for x := lx0 - PlayerWidth; x < lx0; x++ {
+		w.X16 = x * 16
+		if !w.Hit() {
+			// The rectangles overlap, but not the sprites.
+			transparent = true
+			break
+		}
+	}
+	if !transparent {
+		t.Error("every overlap of the rectangles is a hit")
+	}
+
+	w.X16 = (lx0 + TileSize) * 16
+	if !w.Hit() {
+		t.Error("deep overlap is not a hit")
+	}
+}
+
+func TestBotReachesTokyoMostly(t *testing.T) {
+	// The bot doesn't always make it, but it sinks in few runs.
+	const runs = 20
+	sank := 0
+	for seed := uint64(1); seed <= runs; seed++ {
+		w := New(Config{Seed: seed})
+		b := NewBot()
+		for !w.Over && !w.Goal {
+			w.Step(b.Input(w))
+		}
+		if !w.Goal {
+			t.Logf("seed %d: bot sank at %s (%dm)", seed, w.Location, w.Distance())
+			sank++
+		}
+	}
+	if sank > runs/10 {
+		t.Errorf("bot sank in %d of %d runs", sank, runs)
+	}
+}
@@ -0,0 +1,140 @@
+package sim
+
+import (
+	"crypto/sha256"
+	_ "embed"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+)
+
+// stages.json is the route from 八丈島 to 東京, and how it goes on in the
+// endless mode. Stages with "disabled" set are skipped, so they can be kept in
+// the file while they are being designed.
+//
+//go:embed stages.json
+var stagesJSON []byte
+
+var (
+	defaultStages  Stages
+	defaultEndless Endless
+	routeHash      uint64
+)
+
+func init() {
+	s, err := ParseStages(stagesJSON)
+	if err != nil {
+		log.Fatalf("stages.json: %v", err)
+	}
+	defaultStages = s
+
+	e, err := ParseEndless(stagesJSON)
+	if err != nil {
+		log.Fatalf("stages.json: %v", err)
+	}
+	defaultEndless = e
+
+	b, err := json.Marshal(struct {
+		Stages  Stages
+		Endless Endless
+	}{s, e})
+	if err != nil {
+		log.Fatal(err)
+	}
+	h := sha256.Sum256(b)
+	routeHash = binary.BigEndian.Uint64(h[:])
+}
+
+// RouteHash returns the hash of the route and the endless mode embedded in
+// stages.json. Replays record it, as they only play the same run back on the
+// same route.
+func RouteHash() uint64 {
+	return routeHash
+}
+
+// DefaultStages returns the route embedded in stages.json.
+func DefaultStages() Stages {
+	return slices.Clone(defaultStages)
+}
+
+// DefaultEndless returns the endless mode embedded in stages.json.
+func DefaultEndless() Endless {
+	e := defaultEndless
+	e.Waypoints = slices.Clone(e.Waypoints)
+	return e
+}
+
+const (
+	// minSurfGap is the narrowest gap the player can pass through. The end
+	// of the left part of the surf covers the first tile of the gap.
+	minSurfGap = (PlayerWidth+TileSize-1)/TileSize + 1
+	// maxSurfGap leaves at least one tile of surf on each side.
+	maxSurfGap = ScreenWidth/TileSize - 2
+)
+
+// ParseStages parses and validates the stages in JSON.
+func ParseStages(data []byte) (Stages, error) {
+	var f struct {
+		Stages []struct {
+			Stage
+			Disabled bool `json:"disabled"`
+		} `json:"stages"`
+	}
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, err
+	}
+
+	var s Stages
+	for _, v := range f.Stages {
+		if v.Disabled {
+			continue
+		}
+		s = append(s, v.Stage)
+	}
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Validate reports whether the stages make a playable route.
+func (s Stages) Validate() error {
+	if len(s) == 0 {
+		return errors.New("no stages")
+	}
+	if s[0].Dist != 0 {
+		return fmt.Errorf("first stage %s: dist must be 0, got %d", s[0].Name, s[0].Dist)
+	}
+
+	var errs []error
+	for i, v := range s {
+		if v.Name == "" {
+			errs = append(errs, fmt.Errorf("stage #%d: name is empty", i))
+		}
+		if i > 0 && v.Dist <= s[i-1].Dist {
+			errs = append(errs, fmt.Errorf("stage %s: dist %d must be greater than %d of %s", v.Name, v.Dist, s[i-1].Dist, s[i-1].Name))
+		}
+		if v.Speed <= 0 {
+			errs = append(errs, fmt.Errorf("stage %s: speed must be positive, got %d", v.Name, v.Speed))
+		}
+		if v.SurfGap < minSurfGap || v.SurfGap > maxSurfGap {
+			errs = append(errs, fmt.Errorf("stage %s: surfGap must be in [%d, %d], got %d", v.Name, minSurfGap, maxSurfGap, v.SurfGap))
+		}
+		if v.SurfInterval <= 0 {
+			errs = append(errs, fmt.Errorf("stage %s: surfInterval must be positive, got %d", v.Name, v.SurfInterval))
+		}
+		if v.Swing <= 0 || v.Swing > 1 {
+			errs = append(errs, fmt.Errorf("stage %s: swing must be in (0, 1], got %g", v.Name, v.Swing))
+		}
+		if !v.Obstacles.valid() {
+			errs = append(errs, fmt.Errorf("stage %s: obstacles must not be negative, got %+v", v.Name, v.Obstacles))
+		}
+		if !v.Items.valid() {
+			errs = append(errs, fmt.Errorf("stage %s: items must not be negative, got %+v", v.Name, v.Items))
+		}
+	}
+	return errors.Join(errs...)
+}
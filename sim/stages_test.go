@@ -0,0 +1,61 @@
+package sim
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDefaultStages(t *testing.T) {
+	s := DefaultStages()
+	if s[0].Name != "八丈島" || s[len(s)-1].Name != "東京" {
+		t.Fatalf("unexpected route: %v", s)
+	}
+	for _, v := range s {
+		if v.Name == "千葉" || v.Name == "神奈川" {
+			t.Errorf("disabled stage %s is loaded", v.Name)
+		}
+	}
+}
+
+func TestParseStagesInvalid(t *testing.T) {
+	for _, tt := range []struct {
+		json string
+		want string
+	}{
+		{`{"stages": []}`, "no stages"},
+		{`{"stages": [{"name": "a", "dist": 5, "speed": 2, "surfGap": 8, "surfInterval": 10, "swing": 0.5}]}`, "dist must be 0"},
+		{`{"stages": [
+			{"name": "a", "dist": 0, "speed": 2, "surfGap": 8, "surfInterval": 10, "swing": 0.5},
+			{"name": "b", "dist": 0, "speed": 2, "surfGap": 8, "surfInterval": 10, "swing": 0.5}
+		]}`, "must be greater than"},
+		{`{"stages": [{"name": "a", "dist": 0, "speed": 0, "surfGap": 8, "surfInterval": 10, "swing": 0.5}]}`, "speed must be positive"},
+		{`{"stages": [{"name": "a", "dist": 0, "speed": 2, "surfGap": 14, "surfInterval": 10, "swing": 0.5}]}`, "surfGap must be in"},
+		{`{"stages": [{"name": "a", "dist": 0, "speed": 2, "surfGap": 8, "surfInterval": 0, "swing": 0.5}]}`, "surfInterval must be positive"},
+		{`{"stages": [{"name": "a", "dist": 0, "speed": 2, "surfGap": 8, "surfInterval": 10, "swing": 1.5}]}`, "swing must be in"},
+		{`{"stages": [{"name": "a", "dist": 0, "speed": 2, "surfGap": 8, "surfInterval": 10, "swing": 0.5, "obstacles": {"rock": -1}}]}`, "obstacles must not be negative"},
+		{`{"stages": [{"name": "a", "dist": 0, "speed": 2, "surfGap": 8, "surfInterval": 10, "swing": 0.5, "items": {"boost": -1}}]}`, "items must not be negative"},
+		{`{"stages": [{"dist": 0, "speed": 2, "surfGap": 8, "surfInterval": 10, "swing": 0.5}]}`, "name is empty"},
+	} {
+		_, err := ParseStages([]byte(tt.json))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got %v, want %q", tt.json, err, tt.want)
+		}
+	}
+}
+
+func TestParseEndlessInvalid(t *testing.T) {
+	for _, tt := range []struct {
+		json string
+		want string
+	}{
+		{`{}`, "no endless"},
+		{`{"endless": {"interval": 0, "maxSpeed": 8, "minSurfGap": 4, "minSurfInterval": 6, "waypoints": ["a"]}}`, "interval must be positive"},
+		{`{"endless": {"interval": 30, "maxSpeed": 8, "minSurfGap": 1, "minSurfInterval": 6, "waypoints": ["a"]}}`, "minSurfGap must be in"},
+		{`{"endless": {"interval": 30, "maxSpeed": 8, "minSurfGap": 4, "minSurfInterval": 6}}`, "no waypoints"},
+	} {
+		_, err := ParseEndless([]byte(tt.json))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got %v, want %q", tt.json, err, tt.want)
+		}
+	}
+}
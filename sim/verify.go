@@ -0,0 +1,59 @@
+package sim
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrMismatch is returned when a replay doesn't play back as claimed.
+var ErrMismatch = errors.New("sim: replay mismatch")
+
+// ErrOtherRoute is returned when a replay was recorded in a game with other
+// stages.json, where the same inputs play another run.
+var ErrOtherRoute = errors.New("sim: replay of another route")
+
+// Claim is the result of a run claimed with its replay.
+type Claim struct {
+	// Distance is the travelled distance in meters.
+	Distance int
+	// Frame is the frame the run ended at, by sinking or reaching the goal.
+	Frame int
+}
+
+// Play plays the replay back without a window and returns the world at the
+// end of the run. Real runs end exactly at the last input, so it fails with
+// ErrMismatch when the run ends before it or goes on after it, e.g. in a run
+// of an invincible player. It fails with ErrOtherRoute and no world when the
+// replay was recorded on another route.
+func Play(r *Replay) (*World, error) {
+	if r.Route != RouteHash() {
+		return nil, fmt.Errorf("%w: %016x, not %016x of this game", ErrOtherRoute, r.Route, RouteHash())
+	}
+	w := New(r.Config())
+	for _, in := range r.Inputs {
+		if w.Over || w.Goal {
+			return w, fmt.Errorf("%w: the run ended at frame %d before the last input at frame %d", ErrMismatch, w.Frame, len(r.Inputs))
+		}
+		w.Step(in)
+	}
+	if !w.Over && !w.Goal {
+		return w, fmt.Errorf("%w: the run has not ended at the last input at frame %d", ErrMismatch, w.Frame)
+	}
+	return w, nil
+}
+
+// Verify plays the replay back, and checks that the run ends at the claimed
+// frame with the claimed distance.
+func Verify(r *Replay, c Claim) (*World, error) {
+	w, err := Play(r)
+	if err != nil {
+		return w, err
+	}
+	if w.Distance() != c.Distance {
+		return w, fmt.Errorf("%w: the run reached %dm, not %dm", ErrMismatch, w.Distance(), c.Distance)
+	}
+	if w.Frame != c.Frame {
+		return w, fmt.Errorf("%w: the run ended at frame %d, not %d", ErrMismatch, w.Frame, c.Frame)
+	}
+	return w, nil
+}
@@ -0,0 +1,69 @@
+package sim
+
+import (
+	"errors"
+	"testing"
+)
+
+// recordRun records a run that steers every 8 frames until it ends, or for
+// the frames if the player is invincible.
+func recordRun(seed uint64, invincible bool, frames int) (*Replay, *World) {
+	w := New(Config{Seed: seed})
+	w.Invincible = invincible
+	r := &Replay{Route: RouteHash(), Seed: seed}
+	for !w.Over && !w.Goal && w.Frame < frames {
+		in := Input{}
+		if w.Frame%8 == 0 {
+			in = steer(w)
+		}
+		r.Record(in)
+		w.Step(in)
+	}
+	return r, w
+}
+
+func TestVerify(t *testing.T) {
+	r, w := recordRun(3, false, maxReplayFrames)
+	claim := Claim{Distance: w.Distance(), Frame: w.Frame}
+	if _, err := Verify(r, claim); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []Claim{
+		{claim.Distance + 20, claim.Frame},
+		{claim.Distance, claim.Frame + 1},
+	} {
+		if _, err := Verify(r, c); !errors.Is(err, ErrMismatch) {
+			t.Errorf("claim %+v: got %v, want ErrMismatch", c, err)
+		}
+	}
+
+	// A run on another route
+	other := *r
+	other.Route++
+	if _, err := Verify(&other, claim); !errors.Is(err, ErrOtherRoute) {
+		t.Errorf("other route: got %v, want ErrOtherRoute", err)
+	}
+
+	// A run that stopped halfway
+	r.Inputs = r.Inputs[:len(r.Inputs)/2]
+	if _, err := Play(r); !errors.Is(err, ErrMismatch) {
+		t.Errorf("cut replay: got %v, want ErrMismatch", err)
+	}
+}
+
+func TestVerifyInvincible(t *testing.T) {
+	// An invincible player goes through what sinks the others, so the run
+	// ends earlier when it is played back.
+	r, w := recordRun(1, true, 60*60)
+	if w.Over {
+		t.Fatal("invincible player is over")
+	}
+	w2, err := Play(r)
+	if !errors.Is(err, ErrMismatch) {
+		t.Fatalf("got %v, want ErrMismatch", err)
+	}
+	if w2.Frame >= w.Frame {
+		t.Errorf("played back to frame %d, want before %d", w2.Frame, w.Frame)
+	}
+}
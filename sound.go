@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"image"
+	"io"
+	"log"
+	"path"
+	"strings"
+
+	"github.com/shuuuta/shimanuke-chuta/sim"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/audio"
+	"github.com/hajimehoshi/ebiten/v2/audio/wav"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+const (
+	sampleRate = 22050
+
+	soundKey = "sound"
+
+	seGameOverDelay = 30
+)
+
+// The files are named bgm_<name>.wav and se_<name>.wav.
+//
+//go:embed resources/audio/*.wav
+var audioFiles embed.FS
+
+// bgm.json is the BGM of each stage by its name, and the one of the waypoints
+// of the endless mode. It is kept out of the stages of the simulation, which
+// don't play any music.
+//
+//go:embed resources/audio/bgm.json
+var bgmJSON []byte
+
+type bgmConfig struct {
+	Stages  map[string]string `json:"stages"`
+	Endless string            `json:"endless"`
+}
+
+var muteButton = image.Rect(8, screenHeight-56, 128, screenHeight-8)
+
+type soundSettings struct {
+	Volume float64 `json:"volume"`
+	Mute   bool    `json:"mute"`
+}
+
+type sound struct {
+	ctx      *audio.Context
+	settings soundSettings
+
+	bgms      map[string]*audio.Player
+	bgm       *audio.Player
+	bgmName   string
+	stageBGMs bgmConfig
+
+	ses map[string][]byte
+}
+
+func newSound() *sound {
+	s := &sound{
+		ctx:      audio.NewContext(sampleRate),
+		settings: soundSettings{Volume: 0.8},
+		bgms:     map[string]*audio.Player{},
+		ses:      map[string][]byte{},
+	}
+	loadJSON(soundKey, &s.settings)
+	if err := json.Unmarshal(bgmJSON, &s.stageBGMs); err != nil {
+		log.Fatalf("bgm.json: %v", err)
+	}
+
+	entries, err := audioFiles.ReadDir("resources/audio")
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, e := range entries {
+		b, err := audioFiles.ReadFile(path.Join("resources/audio", e.Name()))
+		if err != nil {
+			log.Fatal(err)
+		}
+		stream, err := wav.DecodeWithSampleRate(sampleRate, bytes.NewReader(b))
+		if err != nil {
+			log.Fatalf("%s: %v", e.Name(), err)
+		}
+
+		name := strings.TrimSuffix(e.Name(), ".wav")
+		switch {
+		case strings.HasPrefix(name, "bgm_"):
+			p, err := s.ctx.NewPlayer(audio.NewInfiniteLoop(stream, stream.Length()))
+			if err != nil {
+				log.Fatalf("%s: %v", e.Name(), err)
+			}
+			s.bgms[strings.TrimPrefix(name, "bgm_")] = p
+
+		case strings.HasPrefix(name, "se_"):
+			pcm, err := io.ReadAll(stream)
+			if err != nil {
+				log.Fatalf("%s: %v", e.Name(), err)
+			}
+			s.ses[strings.TrimPrefix(name, "se_")] = pcm
+		}
+	}
+	return s
+}
+
+// validate reports whether every stage and the waypoints of the endless mode
+// have their BGM.
+func (s *sound) validate(stages sim.Stages) error {
+	for _, v := range stages {
+		name, ok := s.stageBGMs.Stages[v.Name]
+		if !ok {
+			return fmt.Errorf("stage %s: no bgm", v.Name)
+		}
+		if _, ok := s.bgms[name]; !ok {
+			return fmt.Errorf("stage %s: unknown bgm %q", v.Name, name)
+		}
+	}
+	if _, ok := s.bgms[s.stageBGMs.Endless]; !ok {
+		return fmt.Errorf("endless: unknown bgm %q", s.stageBGMs.Endless)
+	}
+	return nil
+}
+
+// stageBGM returns the BGM of the stage named name. The stages not in
+// bgm.json are the waypoints of the endless mode.
+func (s *sound) stageBGM(name string) string {
+	if bgm, ok := s.stageBGMs.Stages[name]; ok {
+		return bgm
+	}
+	return s.stageBGMs.Endless
+}
+
+func (s *sound) volume() float64 {
+	if s.settings.Mute {
+		return 0
+	}
+	return s.settings.Volume
+}
+
+// playBGM switches the BGM. Browsers start playing it after the first tap or
+// key press, when the audio context gets ready.
+func (s *sound) playBGM(name string) {
+	if name != s.bgmName {
+		s.stopBGM()
+		s.bgmName = name
+		s.bgm = s.bgms[name]
+		if s.bgm == nil {
+			return
+		}
+		if err := s.bgm.Rewind(); err != nil {
+			log.Printf("Failed to rewind bgm %s: %v", name, err)
+		}
+	}
+	if s.bgm == nil || s.bgm.IsPlaying() || !s.ctx.IsReady() {
+		return
+	}
+	s.bgm.SetVolume(s.volume())
+	s.bgm.Play()
+}
+
+func (s *sound) pauseBGM() {
+	if s.bgm != nil {
+		s.bgm.Pause()
+	}
+}
+
+func (s *sound) stopBGM() {
+	s.pauseBGM()
+	s.bgm = nil
+	s.bgmName = ""
+}
+
+func (s *sound) playSE(name string) {
+	if s.volume() == 0 || !s.ctx.IsReady() {
+		return
+	}
+	pcm, ok := s.ses[name]
+	if !ok {
+		return
+	}
+	p := s.ctx.NewPlayerFromBytes(pcm)
+	p.SetVolume(s.volume())
+	p.Play()
+}
+
+func (s *sound) setVolume(v float64) {
+	s.settings.Volume = min(max(v, 0), 1)
+	s.settings.Mute = false
+	s.apply()
+}
+
+func (s *sound) toggleMute() {
+	s.settings.Mute = !s.settings.Mute
+	s.apply()
+}
+
+func (s *sound) apply() {
+	if s.bgm != nil {
+		s.bgm.SetVolume(s.volume())
+	}
+	saveJSON(soundKey, s.settings)
+}
+
+func (s *sound) String() string {
+	if s.settings.Mute {
+		return "♪ OFF"
+	}
+	return fmt.Sprintf("♪ %d%%", int(s.settings.Volume*100+0.5))
+}
+
+// soundKeys are the keys to mute and to turn the volume down and up, which
+// can't be bound to the actions.
+var soundKeys = []ebiten.Key{ebiten.KeyM, ebiten.KeyMinus, ebiten.KeyEqual}
+
+// updateSoundSettings handles the mute and volume keys, and reports whether
+// the mute button was tapped.
+func (g *Game) updateSoundSettings() bool {
+	// The keys may be being bound to an action.
+	if g.waitingBinding {
+		return false
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyM) {
+		g.sound.toggleMute()
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyMinus) {
+		g.sound.setVolume(g.sound.settings.Volume - 0.1)
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyEqual) {
+		g.sound.setVolume(g.sound.settings.Volume + 0.1)
+	}
+
+	if g.mode != ModeStartMenu {
+		return false
+	}
+	p, ok := g.justPressedPosition()
+	if ok && p.In(muteButton) {
+		g.sound.toggleMute()
+		return true
+	}
+	return false
+}
+
+// updateBGM plays the BGM for the current mode and stage.
+func (g *Game) updateBGM() {
+	switch g.mode {
+	case ModeStartMenu, ModeOnlineLobby:
+		g.sound.playBGM(g.sound.stageBGM(g.world.Stages[0].Name))
+	case ModeGame, ModeGoal:
+		g.sound.playBGM(g.sound.stageBGM(g.world.Location))
+	case ModePause:
+		g.sound.pauseBGM()
+	case ModeGameOver:
+		g.sound.stopBGM()
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"image/color"
+
+	"github.com/shuuuta/shimanuke-chuta/sim"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/text/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+const stageSelectTop = 144
+
+// practiceStages returns the stages that practice runs can start from: the
+// ones already reached in any mode, except the goal.
+func (g *Game) practiceStages() sim.Stages {
+	stages := sim.DefaultStages()
+	return stages[:min(g.records.reached(stages)+1, len(stages)-1)]
+}
+
+func (g *Game) updateStageSelect() {
+	stages := g.practiceStages()
+	// The last row is to go back.
+	i := g.updateMenu(&g.stageCursor, len(stages)+1, stageSelectTop)
+	switch {
+	case i < 0:
+	case i < len(stages):
+		g.startPractice(i)
+	default:
+		g.mode = ModeStartMenu
+	}
+}
+
+// startPractice starts a practice run from the stage.
+func (g *Game) startPractice(stage int) {
+	c := g.config(newSeed())
+	c.StartStage = stage
+	g.init(c)
+	g.run = runPractice
+	g.mode = ModeGame
+}
+
+func (g *Game) drawStageSelect(screen *ebiten.Image) {
+	vector.DrawFilledRect(screen, 0, 0, screenWidth, screenHeight, color.RGBA{0, 0, 0, 128}, false)
+
+	op := &text.DrawOptions{}
+	op.GeoM.Translate(screenWidth/2, 64)
+	op.ColorScale.ScaleWithColor(color.White)
+	op.LineSpacing = middleFontSize
+	op.PrimaryAlign = text.AlignCenter
+	text.Draw(
+		screen,
+		"練習",
+		&text.GoTextFace{
+			Source: k8x12sFont,
+			Size:   middleFontSize,
+		},
+		op,
+	)
+
+	stages := g.practiceStages()
+	labels := make([]string, 0, len(stages)+1)
+	for _, s := range stages {
+		labels = append(labels, s.Name)
+	}
+	labels = append(labels, "もどる")
+	drawMenu(screen, labels, g.stageCursor, stageSelectTop)
+}
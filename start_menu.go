@@ -1,15 +1,103 @@
 package main
 
 import (
+	"fmt"
 	"image/color"
+	"time"
+
+	"github.com/shuuuta/shimanuke-chuta/sim"
 
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/text/v2"
 )
 
+// startMenuMaxTop is the top of the start menu, which goes up when it has
+// more items than the room below.
+const startMenuMaxTop = 288
+
+// startMenuTop returns the top of the start menu of n items.
+func startMenuTop(n int) int {
+	return min(startMenuMaxTop, muteButton.Min.Y-n*menuItemHeight)
+}
+
+type menuItem struct {
+	label  string
+	action func(g *Game)
+	// change is called with -1 or 1 for the left and the right keys, if set.
+	change func(g *Game, d int)
+}
+
+func (g *Game) startMenuItems() []menuItem {
+	items := []menuItem{
+		{"スタート", func(g *Game) {
+			g.mode = ModeGame
+		}, nil},
+		{g.dailyLabel(), func(g *Game) {
+			g.startDaily()
+		}, nil},
+		{"エンドレス", func(g *Game) {
+			g.startEndless()
+		}, nil},
+		{"練習", func(g *Game) {
+			g.stageCursor = 0
+			g.mode = ModeStageSelect
+		}, nil},
+		{"2人対戦", func(g *Game) {
+			g.startRace()
+		}, nil},
+	}
+	if g.relayURL != "" {
+		items = append(items, menuItem{"オンライン対戦", func(g *Game) {
+			g.startOnline()
+		}, nil})
+	}
+	// The items after the difficulty can change with it.
+	items = append(items, menuItem{"難易度 < " + difficultyLabels[g.difficulty] + " >", func(g *Game) {
+		g.changeDifficulty(1)
+	}, (*Game).changeDifficulty})
+	if r, ok := g.ghostRuns[normalRecordMode(g.difficulty)]; ok {
+		items = append(items, menuItem{fmt.Sprintf("ベストに挑戦 %.1fkm", float64(r.Distance)/1000), func(g *Game) {
+			g.startGhostRace()
+		}, nil})
+	}
+	return append(items, menuItem{"キー設定", func(g *Game) {
+		g.settingsCursor = 0
+		g.mode = ModeSettings
+	}, nil})
+}
+
+// dailyLabel returns the menu label of the daily challenge with the best of
+// today.
+func (g *Game) dailyLabel() string {
+	label := "今日の島抜け"
+	if rec, ok := g.records[dailyRecordMode(sim.DailyDate(time.Now()), g.difficulty)]; ok {
+		label += fmt.Sprintf(" %.1fkm", float64(rec.Distance)/1000)
+	}
+	return label
+}
+
+func (g *Game) updateStartMenu() {
+	items := g.startMenuItems()
+	g.menuCursor = min(g.menuCursor, len(items)-1)
+	if change := items[g.menuCursor].change; change != nil {
+		if g.isActionJustPressed(actionLeft) {
+			change(g, -1)
+			return
+		}
+		if g.isActionJustPressed(actionRight) {
+			change(g, 1)
+			return
+		}
+	}
+
+	if i := g.updateMenu(&g.menuCursor, len(items), startMenuTop(len(items))); i >= 0 {
+		items[i].action(g)
+	}
+}
+
 func (g *Game) drawStartMenu(screen *ebiten.Image) {
 	op := &text.DrawOptions{}
-	op.GeoM.Translate(screenWidth/2, titleFontSize*3)
+	op.GeoM.Translate(screenWidth/2, titleFontSize*1.5)
 	op.ColorScale.ScaleWithColor(color.White)
 	op.LineSpacing = titleFontSize
 	op.PrimaryAlign = text.AlignCenter
@@ -23,14 +111,41 @@ func (g *Game) drawStartMenu(screen *ebiten.Image) {
 		op,
 	)
 
+	items := g.startMenuItems()
+	top := startMenuTop(len(items))
+
+	// The record makes room for a long menu.
+	if rec, ok := g.records[g.recordMode()]; ok && top >= titleFontSize*3+fontSize {
+		op = &text.DrawOptions{}
+		op.GeoM.Translate(screenWidth/2, titleFontSize*3)
+		op.ColorScale.ScaleWithColor(color.White)
+		op.LineSpacing = fontSize
+		op.PrimaryAlign = text.AlignCenter
+		text.Draw(
+			screen,
+			rec.String(),
+			&text.GoTextFace{
+				Source: misakiFont,
+				Size:   fontSize,
+			},
+			op,
+		)
+	}
+
+	labels := make([]string, len(items))
+	for i, item := range items {
+		labels[i] = item.label
+	}
+	drawMenu(screen, labels, g.menuCursor, top)
+
 	op = &text.DrawOptions{}
-	op.GeoM.Translate(screenWidth/2, titleFontSize*5)
+	op.GeoM.Translate(float64(muteButton.Min.X+8), float64(muteButton.Min.Y+muteButton.Dy()/2))
 	op.ColorScale.ScaleWithColor(color.White)
 	op.LineSpacing = fontSize
-	op.PrimaryAlign = text.AlignCenter
+	op.SecondaryAlign = text.AlignCenter
 	text.Draw(
 		screen,
-		"- TAP or PRESS SPACE KEY -",
+		g.sound.String(),
 		&text.GoTextFace{
 			Source: misakiFont,
 			Size:   fontSize,
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log"
+)
+
+// storage persists small data across runs: localStorage on browsers, files
+// in the user config directory on desktops.
+type storage interface {
+	// load returns fs.ErrNotExist when nothing is saved for the key.
+	load(key string) ([]byte, error)
+	save(key string, data []byte) error
+}
+
+// store is the storage for the current platform.
+var store storage = newStorage()
+
+// loadJSON decodes the data saved for the key into v. v is left untouched
+// when nothing is saved yet.
+func loadJSON(key string, v any) {
+	b, err := store.load(key)
+	if errors.Is(err, fs.ErrNotExist) {
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to load %s: %v", key, err)
+		return
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		log.Printf("Failed to decode %s: %v", key, err)
+	}
+}
+
+func saveJSON(key string, v any) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("Failed to encode %s: %v", key, err)
+		return
+	}
+	if err := store.save(key, b); err != nil {
+		log.Printf("Failed to save %s: %v", key, err)
+	}
+}
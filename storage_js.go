@@ -0,0 +1,43 @@
+//go:build js
+
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"syscall/js"
+)
+
+const storagePrefix = "shimanuke-chuta/"
+
+type localStorage struct{}
+
+func newStorage() storage {
+	return localStorage{}
+}
+
+func (localStorage) load(key string) (b []byte, err error) {
+	// localStorage throws when it is disabled, e.g. by privacy settings.
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("localStorage: %v", r)
+		}
+	}()
+
+	v := js.Global().Get("localStorage").Call("getItem", storagePrefix+key)
+	if v.IsNull() {
+		return nil, fs.ErrNotExist
+	}
+	return []byte(v.String()), nil
+}
+
+func (localStorage) save(key string, data []byte) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("localStorage: %v", r)
+		}
+	}()
+
+	js.Global().Get("localStorage").Call("setItem", storagePrefix+key, string(data))
+	return nil
+}
@@ -0,0 +1,42 @@
+//go:build !js
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// fileStorage saves each key as a file under the user config directory.
+type fileStorage struct{}
+
+func newStorage() storage {
+	return fileStorage{}
+}
+
+func (fileStorage) path(key string) (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "shimanuke-chuta", key+".json"), nil
+}
+
+func (s fileStorage) load(key string) ([]byte, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(p)
+}
+
+func (s fileStorage) save(key string, data []byte) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0777); err != nil {
+		return err
+	}
+	return os.WriteFile(p, data, 0666)
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/shuuuta/shimanuke-chuta/sim"
+)
+
+// The actions of the environment.
+const (
+	actionNone = iota
+	actionLeft
+	actionRight
+)
+
+// envSurfs is the number of the surfs ahead in an observation.
+const envSurfs = 3
+
+// envRequest is a line of the input of the environment.
+type envRequest struct {
+	// Cmd is "reset" or "step".
+	Cmd string `json:"cmd"`
+
+	// For reset
+	Seed       uint64 `json:"seed"`
+	Difficulty string `json:"difficulty"`
+	Endless    bool   `json:"endless"`
+	StartStage int    `json:"startStage"`
+
+	// For step
+	Action int `json:"action"`
+}
+
+// envSurf is a surf ahead of the player. The positions are in pixels.
+type envSurf struct {
+	// DY is how far the bottom of the surf is above the top of the player.
+	DY int `json:"dy"`
+	// GapLeft and GapRight are the edges of the gap.
+	GapLeft  int `json:"gapLeft"`
+	GapRight int `json:"gapRight"`
+}
+
+// envObstacle is an obstacle ahead of the player. The positions are in pixels.
+type envObstacle struct {
+	// Kind is "rock", "whirlpool" or "driftwood".
+	Kind string `json:"kind"`
+	// DY is how far the bottom of the obstacle is above the top of the
+	// player, and X and Width are the left edge and the width.
+	DY    int `json:"dy"`
+	X     int `json:"x"`
+	Width int `json:"width"`
+	// VX16 is the horizontal velocity of driftwood.
+	VX16 int `json:"vx16"`
+}
+
+// envItem is a power-up ahead of the player. The positions are in pixels.
+type envItem struct {
+	// Kind is "shield", "calm" or "boost".
+	Kind string `json:"kind"`
+	// DY is how far the bottom of the item is above the top of the player,
+	// and X is the left edge.
+	DY int `json:"dy"`
+	X  int `json:"x"`
+}
+
+type envObservation struct {
+	// X is the left edge of the player, and VX16 is the horizontal velocity
+	// in 1/16 pixels per frame.
+	X     int `json:"x"`
+	VX16  int `json:"vx16"`
+	Speed int `json:"speed"`
+	// Wave is the direction the wave pushes the player: -1 to the left, 1 to
+	// the right or 0.
+	Wave      int           `json:"wave"`
+	Surfs     []envSurf     `json:"surfs"`
+	Obstacles []envObstacle `json:"obstacles"`
+	Items     []envItem     `json:"items"`
+	// Shield is whether the player has a shield, and Calm and Boost are the
+	// frames left of the power-ups.
+	Shield bool `json:"shield"`
+	Calm   int  `json:"calm"`
+	Boost  int  `json:"boost"`
+}
+
+type envInfo struct {
+	Frame    int    `json:"frame"`
+	Distance int    `json:"distance"`
+	Location string `json:"location"`
+	Goal     bool   `json:"goal"`
+}
+
+// envResponse is a line of the output of the environment.
+type envResponse struct {
+	Obs *envObservation `json:"obs,omitempty"`
+	// Reward is the distance travelled in the step in meters.
+	Reward int      `json:"reward"`
+	Done   bool     `json:"done"`
+	Info   *envInfo `json:"info,omitempty"`
+}
+
+// envError is the response to a bad request.
+type envError struct {
+	Error string `json:"error"`
+}
+
+type env struct {
+	world     *sim.World
+	maxFrames int
+}
+
+func observe(w *sim.World) *envObservation {
+	o := &envObservation{
+		X:         w.X16 / 16,
+		VX16:      w.VX16,
+		Speed:     w.Speed,
+		Surfs:     []envSurf{},
+		Obstacles: []envObstacle{},
+		Items:     []envItem{},
+		Shield:    w.Shield,
+		Calm:      w.Calm,
+		Boost:     w.Boost,
+	}
+	switch d := w.WaveDirection(); {
+	case d < 0:
+		o.Wave = -1
+	case d > 0:
+		o.Wave = 1
+	}
+
+	py := w.Y16/16 - w.CameraY
+	for _, s := range w.Surfs {
+		if s.Y+w.CameraY >= py+sim.PlayerHeight {
+			// Passed
+			continue
+		}
+		// The gap is between the end tiles, which are half solid.
+		o.Surfs = append(o.Surfs, envSurf{
+			DY:       py - (s.Y + w.CameraY + sim.SurfHeight),
+			GapLeft:  (s.LeftWidth + 1) * sim.TileSize,
+			GapRight: (s.LeftWidth + s.Gap) * sim.TileSize,
+		})
+		if len(o.Surfs) == envSurfs {
+			break
+		}
+	}
+	for _, ob := range w.Obstacles {
+		if ob.Y+w.CameraY >= py+sim.PlayerHeight {
+			// Passed
+			continue
+		}
+		width, height := ob.Size()
+		o.Obstacles = append(o.Obstacles, envObstacle{
+			Kind:  ob.Kind.String(),
+			DY:    py - (ob.Y + w.CameraY + height),
+			X:     ob.X16 / 16,
+			Width: width,
+			VX16:  ob.VX16,
+		})
+	}
+	for _, it := range w.Items {
+		if it.Y+w.CameraY >= py+sim.PlayerHeight {
+			// Passed
+			continue
+		}
+		o.Items = append(o.Items, envItem{
+			Kind: it.Kind.String(),
+			DY:   py - (it.Y + w.CameraY + sim.ItemSize),
+			X:    it.X,
+		})
+	}
+	return o
+}
+
+func (e *env) response(reward int) envResponse {
+	w := e.world
+	return envResponse{
+		Obs:    observe(w),
+		Reward: reward,
+		Done:   w.Over || w.Goal || w.Frame >= e.maxFrames,
+		Info: &envInfo{
+			Frame:    w.Frame,
+			Distance: w.Distance(),
+			Location: w.Location,
+			Goal:     w.Goal,
+		},
+	}
+}
+
+func (e *env) handle(req envRequest) (envResponse, error) {
+	switch req.Cmd {
+	case "reset":
+		d := sim.DifficultyNormal
+		if req.Difficulty != "" {
+			var err error
+			d, err = parseDifficulty(req.Difficulty)
+			if err != nil {
+				return envResponse{}, err
+			}
+		}
+		e.world = sim.New(sim.Config{
+			Seed:       req.Seed,
+			Difficulty: d,
+			Endless:    req.Endless,
+			StartStage: req.StartStage,
+		})
+		return e.response(0), nil
+
+	case "step":
+		w := e.world
+		if w == nil {
+			return envResponse{}, errors.New("step before reset")
+		}
+		if w.Over || w.Goal || w.Frame >= e.maxFrames {
+			return envResponse{}, errors.New("step after done")
+		}
+		var in sim.Input
+		switch req.Action {
+		case actionNone:
+		case actionLeft:
+			in.Left = true
+		case actionRight:
+			in.Right = true
+		default:
+			return envResponse{}, fmt.Errorf("unknown action %d", req.Action)
+		}
+		d := w.Distance()
+		w.Step(in)
+		return e.response(w.Distance() - d), nil
+	}
+	return envResponse{}, fmt.Errorf("unknown cmd %q", req.Cmd)
+}
+
+// serveEnv reads requests from r and writes the responses to w, a line each,
+// until r ends.
+func serveEnv(r io.Reader, w io.Writer, maxFrames int) error {
+	e := &env{maxFrames: maxFrames}
+	sc := bufio.NewScanner(r)
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	for sc.Scan() {
+		var req envRequest
+		var res any
+		err := json.Unmarshal(sc.Bytes(), &req)
+		if err == nil {
+			res, err = e.handle(req)
+		}
+		if err != nil {
+			res = envError{Error: err.Error()}
+		}
+		if err := enc.Encode(res); err != nil {
+			return err
+		}
+		// The agent waits for the response before the next request.
+		if err := bw.Flush(); err != nil {
+			return err
+		}
+	}
+	return sc.Err()
+}
+
+func runEnv(args []string) error {
+	// Parse flags
+	flag := flag.NewFlagSet("env", flag.ExitOnError)
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: go run ./tool env [arguments]")
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+
+	maxFrames := flag.Int("frames", 60*60*30, "frames to end an episode after")
+	flag.Parse(args)
+
+	if flag.NArg() > 0 {
+		fmt.Fprintln(os.Stderr, "unexpected arguments:", flag.Args())
+		flag.Usage()
+	}
+
+	return serveEnv(os.Stdin, os.Stdout, *maxFrames)
+}
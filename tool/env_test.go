@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestServeEnv(t *testing.T) {
+	in := strings.Join([]string{
+		`{"cmd":"step"}`,
+		`{"cmd":"reset","seed":1,"difficulty":"hard"}`,
+		`{"cmd":"step","action":2}`,
+		`{"cmd":"step","action":9}`,
+		`not json`,
+	}, "\n")
+	var out strings.Builder
+	if err := serveEnv(strings.NewReader(in), &out, 100); err != nil {
+		t.Fatal(err)
+	}
+
+	var lines []map[string]any
+	sc := bufio.NewScanner(strings.NewReader(out.String()))
+	for sc.Scan() {
+		var m map[string]any
+		if err := json.Unmarshal(sc.Bytes(), &m); err != nil {
+			t.Fatalf("%q: %v", sc.Text(), err)
+		}
+		lines = append(lines, m)
+	}
+	if len(lines) != 5 {
+		t.Fatalf("got %d responses, want 5:\n%s", len(lines), out.String())
+	}
+	for _, i := range []int{0, 3, 4} {
+		if _, ok := lines[i]["error"]; !ok {
+			t.Errorf("response #%d is not an error: %v", i, lines[i])
+		}
+	}
+	obs := lines[2]["obs"].(map[string]any)
+	if obs["vx16"].(float64) <= 0 || lines[2]["reward"].(float64) <= 0 {
+		t.Errorf("step to the right: %v", lines[2])
+	}
+}
+
+func TestEnvDone(t *testing.T) {
+	e := &env{maxFrames: 10}
+	if _, err := e.handle(envRequest{Cmd: "reset", Seed: 1}); err != nil {
+		t.Fatal(err)
+	}
+	var res envResponse
+	for !res.Done {
+		var err error
+		res, err = e.handle(envRequest{Cmd: "step"})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if res.Info.Frame != 10 {
+		t.Errorf("done at frame %d, want 10", res.Info.Frame)
+	}
+	if _, err := e.handle(envRequest{Cmd: "step"}); err == nil {
+		t.Error("step after done is accepted")
+	}
+}
@@ -29,6 +29,21 @@ func main() {
 	case "update":
 		err = update(os.Args[2:])
 
+	case "sim":
+		err = simulate(os.Args[2:])
+
+	case "env":
+		err = runEnv(os.Args[2:])
+
+	case "relay":
+		err = runRelay(os.Args[2:])
+
+	case "scoreserver":
+		err = runScoreServer(os.Args[2:])
+
+	case "verify":
+		err = verify(os.Args[2:])
+
 	default:
 		usage := `usage: go run ./tool <command> [arguments]
 
@@ -38,6 +53,12 @@ commands:
         dist      copy the artifacts to the 'dist' directory
         dist -zip bundle the artifacts as 'dist.zip'
         update    update dependencies and necessary files
+        sim       simulate runs to see the balance of the stages
+        env       run the game as an environment for agents over stdin/stdout
+        relay     run a relay server for the online race
+        scoreserver
+                  run a leaderboard server that checks the replays of the scores
+        verify    play a replay back to check the claimed distance and frame
 
 tips:
         To modify the contents of the distribution, edit dist.go.
@@ -0,0 +1,408 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/shuuuta/shimanuke-chuta/online"
+	"github.com/shuuuta/shimanuke-chuta/online/websocket"
+	"github.com/shuuuta/shimanuke-chuta/sim"
+)
+
+const (
+	// relaySendBuffer is the number of the messages queued for a client. A
+	// client that can't keep up with it is disconnected.
+	relaySendBuffer = 256
+	// maxNameLength is the longest name of a player in characters.
+	maxNameLength = 12
+)
+
+// relayClient is a client connected to the relay.
+type relayClient struct {
+	online.Player
+	conn *websocket.Conn
+	send chan online.Message
+
+	// joined is set while the client waits in the lobby, and racing while
+	// it is in the race.
+	joined bool
+	racing bool
+}
+
+// relay passes the positions of the boats between the clients of a race. See
+// the package online for the protocol.
+type relay struct {
+	// countdown is the number of the ticks to count down before a race, and
+	// tick is the duration of a tick.
+	countdown int
+	tick      time.Duration
+	// minPlayers and maxPlayers are the numbers of the players to start a
+	// race with.
+	minPlayers int
+	maxPlayers int
+	difficulty sim.Difficulty
+
+	mu      sync.Mutex
+	nextID  int
+	clients map[int]*relayClient
+	racing  bool
+	// counting is set during a countdown, and generation is increased to
+	// cancel it.
+	counting   bool
+	generation int
+	standings  map[int]*online.Standing
+}
+
+func newRelay() *relay {
+	return &relay{
+		countdown:  5,
+		tick:       time.Second,
+		minPlayers: 2,
+		maxPlayers: 8,
+		clients:    map[int]*relayClient{},
+	}
+}
+
+func (r *relay) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	conn, err := websocket.Upgrade(w, req)
+	if err != nil {
+		log.Printf("relay: %v", err)
+		return
+	}
+
+	r.mu.Lock()
+	r.nextID++
+	c := &relayClient{
+		Player: online.Player{ID: r.nextID, Name: fmt.Sprintf("Player %d", r.nextID)},
+		conn:   conn,
+		send:   make(chan online.Message, relaySendBuffer),
+	}
+	r.clients[c.ID] = c
+	r.sendTo(c, online.Message{Type: online.TypeWelcome, ID: c.ID})
+	r.mu.Unlock()
+	log.Printf("relay: player %d connected from %s", c.ID, req.RemoteAddr)
+
+	go func() {
+		for m := range c.send {
+			b, err := json.Marshal(m)
+			if err != nil {
+				panic(err)
+			}
+			if err := conn.WriteMessage(b); err != nil {
+				break
+			}
+		}
+		conn.Close()
+	}()
+
+	for {
+		b, err := conn.ReadMessage()
+		if err != nil {
+			break
+		}
+		var m online.Message
+		if err := json.Unmarshal(b, &m); err != nil {
+			r.mu.Lock()
+			r.sendTo(c, online.Message{Type: online.TypeError, Error: "invalid message: " + err.Error()})
+			r.mu.Unlock()
+			continue
+		}
+		r.handle(c, m)
+	}
+
+	r.mu.Lock()
+	r.leave(c)
+	r.mu.Unlock()
+	log.Printf("relay: player %d disconnected", c.ID)
+}
+
+// sendTo queues the message to the client. r.mu must be held.
+func (r *relay) sendTo(c *relayClient, m online.Message) {
+	if _, ok := r.clients[c.ID]; !ok {
+		return
+	}
+	select {
+	case c.send <- m:
+	default:
+		log.Printf("relay: player %d is too slow", c.ID)
+		r.leave(c)
+	}
+}
+
+// sortedClients returns the clients that satisfy f in the order they have
+// connected. r.mu must be held.
+func (r *relay) sortedClients(f func(c *relayClient) bool) []*relayClient {
+	var cs []*relayClient
+	for _, c := range r.clients {
+		if f(c) {
+			cs = append(cs, c)
+		}
+	}
+	slices.SortFunc(cs, func(a, b *relayClient) int {
+		return a.ID - b.ID
+	})
+	return cs
+}
+
+func (r *relay) lobby() []*relayClient {
+	return r.sortedClients(func(c *relayClient) bool {
+		return c.joined
+	})
+}
+
+func (r *relay) racers() []*relayClient {
+	return r.sortedClients(func(c *relayClient) bool {
+		return c.racing
+	})
+}
+
+func players(cs []*relayClient) []online.Player {
+	ps := make([]online.Player, len(cs))
+	for i, c := range cs {
+		ps[i] = c.Player
+	}
+	return ps
+}
+
+func (r *relay) handle(c *relayClient, m online.Message) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch m.Type {
+	case online.TypeJoin:
+		if c.racing {
+			r.sendTo(c, online.Message{Type: online.TypeError, Error: "already in the race"})
+			return
+		}
+		if name := strings.TrimSpace(m.Name); name != "" {
+			if utf8.RuneCountInString(name) > maxNameLength {
+				name = string([]rune(name)[:maxNameLength])
+			}
+			c.Name = name
+		}
+		c.joined = true
+		r.updateLobby()
+
+	case online.TypePosition:
+		s, ok := r.standings[c.ID]
+		if !c.racing || !ok {
+			// The position may arrive after the race.
+			return
+		}
+		if s.Over || s.Goal {
+			return
+		}
+		s.Distance = m.Distance
+		s.Frame = m.Frame
+		s.Over = m.Over
+		s.Goal = m.Goal
+
+		m.ID = c.ID
+		for _, o := range r.racers() {
+			if o != c {
+				r.sendTo(o, m)
+			}
+		}
+		r.finishIfDone()
+
+	default:
+		r.sendTo(c, online.Message{Type: online.TypeError, Error: fmt.Sprintf("unknown type %q", m.Type)})
+	}
+}
+
+// leave removes the client. r.mu must be held.
+func (r *relay) leave(c *relayClient) {
+	if _, ok := r.clients[c.ID]; !ok {
+		return
+	}
+	delete(r.clients, c.ID)
+	close(c.send)
+
+	if c.joined {
+		c.joined = false
+		r.updateLobby()
+	}
+	if c.racing {
+		c.racing = false
+		if s := r.standings[c.ID]; !s.Over && !s.Goal {
+			s.Left = true
+		}
+		for _, o := range r.racers() {
+			r.sendTo(o, online.Message{Type: online.TypeLeave, ID: c.ID})
+		}
+		r.finishIfDone()
+	}
+}
+
+// updateLobby tells the players in the lobby who are waiting, and starts the
+// countdown if there are enough of them. r.mu must be held.
+func (r *relay) updateLobby() {
+	lobby := r.lobby()
+	for _, c := range lobby {
+		r.sendTo(c, online.Message{Type: online.TypeLobby, Players: players(lobby)})
+	}
+	if r.racing || r.counting || len(lobby) < r.minPlayers {
+		return
+	}
+	r.counting = true
+	r.generation++
+	go r.countDown(r.generation)
+}
+
+func (r *relay) countDown(generation int) {
+	for n := r.countdown; ; n-- {
+		r.mu.Lock()
+		if r.generation != generation {
+			r.mu.Unlock()
+			return
+		}
+		lobby := r.lobby()
+		if len(lobby) < r.minPlayers {
+			r.counting = false
+			r.mu.Unlock()
+			return
+		}
+		if n == 0 {
+			r.counting = false
+			r.start(lobby)
+			r.mu.Unlock()
+			return
+		}
+		for _, c := range lobby {
+			r.sendTo(c, online.Message{Type: online.TypeCountdown, Count: n})
+		}
+		r.mu.Unlock()
+		time.Sleep(r.tick)
+	}
+}
+
+// start starts a race of the players in the lobby. r.mu must be held.
+func (r *relay) start(lobby []*relayClient) {
+	if len(lobby) > r.maxPlayers {
+		lobby = lobby[:r.maxPlayers]
+	}
+	r.racing = true
+	r.standings = map[int]*online.Standing{}
+	for _, c := range lobby {
+		c.joined = false
+		c.racing = true
+		r.standings[c.ID] = &online.Standing{Player: c.Player}
+	}
+	m := online.Message{
+		Type:       online.TypeStart,
+		Seed:       rand.Uint64(),
+		Difficulty: r.difficulty,
+		Players:    players(lobby),
+	}
+	for _, c := range lobby {
+		r.sendTo(c, m)
+	}
+	log.Printf("relay: started a race of %d players with seed %d", len(lobby), m.Seed)
+
+	// The rest wait for the next race.
+	r.updateLobby()
+}
+
+// finishIfDone sends the ranking when every player has sunk, reached the
+// goal or left. r.mu must be held.
+func (r *relay) finishIfDone() {
+	if !r.racing {
+		return
+	}
+	for _, s := range r.standings {
+		if !s.Over && !s.Goal && !s.Left {
+			return
+		}
+	}
+
+	m := online.Message{Type: online.TypeResult, Ranking: ranking(r.standings)}
+	for _, c := range r.racers() {
+		c.racing = false
+		r.sendTo(c, m)
+	}
+	r.racing = false
+	r.standings = nil
+	r.updateLobby()
+}
+
+// ranking sorts the standings by the distance. Reaching the goal earlier is
+// better among those who have reached it, and the players who have left are
+// the last.
+func ranking(standings map[int]*online.Standing) []online.Standing {
+	var rs []online.Standing
+	for _, s := range standings {
+		rs = append(rs, *s)
+	}
+	slices.SortFunc(rs, func(a, b online.Standing) int {
+		switch {
+		case a.Left != b.Left:
+			if a.Left {
+				return 1
+			}
+			return -1
+		case a.Goal != b.Goal:
+			if a.Goal {
+				return -1
+			}
+			return 1
+		case a.Goal && a.Frame != b.Frame:
+			return a.Frame - b.Frame
+		case a.Distance != b.Distance:
+			return b.Distance - a.Distance
+		}
+		return a.ID - b.ID
+	})
+	return rs
+}
+
+func runRelay(args []string) error {
+	// Parse flags
+	flag := flag.NewFlagSet("relay", flag.ExitOnError)
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: go run ./tool relay [arguments]")
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+
+	addr := flag.String("http", "localhost:8081", "HTTP service address")
+	countdown := flag.Int("countdown", 5, "seconds to count down before a race")
+	minPlayers := flag.Int("min", 2, "number of the players to start a race with")
+	maxPlayers := flag.Int("max", 8, "maximum number of the players in a race")
+	difficulty := flag.String("difficulty", "normal", "difficulty: easy, normal or hard")
+	flag.Parse(args)
+
+	if flag.NArg() > 0 {
+		fmt.Fprintln(os.Stderr, "unexpected arguments:", flag.Args())
+		flag.Usage()
+	}
+
+	d, err := parseDifficulty(*difficulty)
+	if err != nil {
+		return err
+	}
+	if *minPlayers < 1 || *maxPlayers < *minPlayers {
+		return fmt.Errorf("wrong numbers of the players: -min %d, -max %d", *minPlayers, *maxPlayers)
+	}
+	if *countdown < 0 {
+		return fmt.Errorf("wrong seconds of the countdown: -countdown %d", *countdown)
+	}
+
+	r := newRelay()
+	r.countdown = *countdown
+	r.minPlayers = *minPlayers
+	r.maxPlayers = *maxPlayers
+	r.difficulty = d
+
+	log.Printf("relay: listening on ws://%s/", *addr)
+	return http.ListenAndServe(*addr, r)
+}
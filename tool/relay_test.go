@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shuuuta/shimanuke-chuta/online"
+	"github.com/shuuuta/shimanuke-chuta/online/websocket"
+)
+
+type relayTestClient struct {
+	t    *testing.T
+	conn *websocket.Conn
+}
+
+func dialRelay(t *testing.T, url string) *relayTestClient {
+	conn, err := websocket.Dial("ws" + strings.TrimPrefix(url, "http"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+	})
+	return &relayTestClient{t, conn}
+}
+
+func (c *relayTestClient) send(m online.Message) {
+	b, err := json.Marshal(m)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if err := c.conn.WriteMessage(b); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// expect skips the messages until the one of the type.
+func (c *relayTestClient) expect(typ online.Type) online.Message {
+	c.t.Helper()
+	for {
+		b, err := c.conn.ReadMessage()
+		if err != nil {
+			c.t.Fatalf("waiting for %s: %v", typ, err)
+		}
+		var m online.Message
+		if err := json.Unmarshal(b, &m); err != nil {
+			c.t.Fatal(err)
+		}
+		if m.Type == typ {
+			return m
+		}
+	}
+}
+
+func TestRelay(t *testing.T) {
+	r := newRelay()
+	r.countdown = 2
+	r.tick = 50 * time.Millisecond
+	s := httptest.NewServer(r)
+	defer s.Close()
+
+	a := dialRelay(t, s.URL)
+	b := dialRelay(t, s.URL)
+	c := dialRelay(t, s.URL)
+	idA := a.expect(online.TypeWelcome).ID
+	idB := b.expect(online.TypeWelcome).ID
+	idC := c.expect(online.TypeWelcome).ID
+
+	a.send(online.Message{Type: online.TypeJoin, Name: "あ"})
+	b.send(online.Message{Type: online.TypeJoin, Name: "い"})
+	c.send(online.Message{Type: online.TypeJoin, Name: "う"})
+
+	// a may join after the countdown has started with b and c.
+	if n := a.expect(online.TypeCountdown).Count; n < 1 || n > 2 {
+		t.Errorf("countdown %d, want 1 or 2", n)
+	}
+	start := a.expect(online.TypeStart)
+	if b.expect(online.TypeStart).Seed != start.Seed {
+		t.Error("different seeds")
+	}
+	c.expect(online.TypeStart)
+
+	a.send(online.Message{Type: online.TypePosition, Frame: 10, X16: 160, Distance: 3})
+	if m := b.expect(online.TypePosition); m.ID != idA || m.X16 != 160 {
+		t.Errorf("relayed position: %+v", m)
+	}
+
+	// c disconnects, and a sinks after b.
+	c.conn.Close()
+	if id := a.expect(online.TypeLeave).ID; id != idC {
+		t.Errorf("left %d, want %d", id, idC)
+	}
+	b.send(online.Message{Type: online.TypePosition, Frame: 100, Distance: 50, Over: true})
+	a.send(online.Message{Type: online.TypePosition, Frame: 200, Distance: 80, Over: true})
+
+	res := a.expect(online.TypeResult)
+	if len(res.Ranking) != 3 {
+		t.Fatalf("ranking: %+v", res.Ranking)
+	}
+	for i, want := range []int{idA, idB, idC} {
+		if got := res.Ranking[i].ID; got != want {
+			t.Errorf("#%d is %d, want %d", i+1, got, want)
+		}
+	}
+	if !res.Ranking[2].Left || res.Ranking[0].Name != "あ" {
+		t.Errorf("ranking: %+v", res.Ranking)
+	}
+	b.expect(online.TypeResult)
+}
@@ -0,0 +1,359 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/shuuuta/shimanuke-chuta/leaderboard"
+	"github.com/shuuuta/shimanuke-chuta/sim"
+)
+
+const (
+	// maxSubmissionSize is far more than the submission of an hour of run.
+	maxSubmissionSize = 1 << 20
+	// maxTop is the most entries returned at once.
+	maxTop = 100
+)
+
+// scoreRecord is an entry in the store with the replay to check it again
+// later.
+type scoreRecord struct {
+	leaderboard.Entry
+	Replay []byte `json:"replay"`
+}
+
+// scoreStore keeps the scores in a JSON file.
+type scoreStore struct {
+	path string
+
+	mu      sync.Mutex
+	nextID  int
+	records []scoreRecord
+}
+
+func openScoreStore(path string) (*scoreStore, error) {
+	s := &scoreStore{path: path, nextID: 1}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &s.records); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for _, r := range s.records {
+		s.nextID = max(s.nextID, r.ID+1)
+	}
+	return s, nil
+}
+
+// save writes the scores into a temporary file first so that a crash never
+// leaves a broken store.
+func (s *scoreStore) save() error {
+	b, err := json.MarshalIndent(s.records, "", "\t")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+var errDuplicateScore = errors.New("the replay has already been submitted")
+
+func (s *scoreStore) add(e leaderboard.Entry, replay []byte) (leaderboard.Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, r := range s.records {
+		if r.ReplayHash == e.ReplayHash {
+			return leaderboard.Entry{}, errDuplicateScore
+		}
+	}
+	e.ID = s.nextID
+	s.records = append(s.records, scoreRecord{Entry: e, Replay: replay})
+	if err := s.save(); err != nil {
+		s.records = s.records[:len(s.records)-1]
+		return leaderboard.Entry{}, err
+	}
+	s.nextID++
+	return e, nil
+}
+
+// top returns the n best entries that satisfy f: the farthest first, and the
+// fastest among the same distance.
+func (s *scoreStore) top(n int, f func(e leaderboard.Entry) bool) []leaderboard.Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	es := []leaderboard.Entry{}
+	for _, r := range s.records {
+		if f(r.Entry) {
+			es = append(es, r.Entry)
+		}
+	}
+	slices.SortFunc(es, func(a, b leaderboard.Entry) int {
+		switch {
+		case a.Distance != b.Distance:
+			return b.Distance - a.Distance
+		case a.Frames != b.Frames:
+			return a.Frames - b.Frames
+		}
+		return a.ID - b.ID
+	})
+	return es[:min(n, len(es))]
+}
+
+// scoreServer is the HTTP API of the leaderboard. See the package
+// leaderboard for the protocol.
+type scoreServer struct {
+	store *scoreStore
+	// key is the shared key of the signatures. Submissions are not signed
+	// when it is empty.
+	key []byte
+	top int
+	now func() time.Time
+}
+
+// httpError is an error with the status code to respond with.
+type httpError struct {
+	code int
+	msg  string
+}
+
+func (e *httpError) Error() string {
+	return e.msg
+}
+
+func newHTTPError(code int, format string, args ...any) *httpError {
+	return &httpError{code: code, msg: fmt.Sprintf(format, args...)}
+}
+
+func (s *scoreServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// The game on any origin can submit.
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, "+leaderboard.SignatureHeader)
+
+	if r.URL.Path != "/scores" {
+		http.NotFound(w, r)
+		return
+	}
+
+	var v any
+	var err error
+	code := http.StatusOK
+	switch r.Method {
+	case http.MethodOptions:
+		w.WriteHeader(http.StatusNoContent)
+		return
+	case http.MethodGet:
+		v, err = s.list(r)
+	case http.MethodPost:
+		v, err = s.submit(r)
+		code = http.StatusCreated
+	default:
+		err = newHTTPError(http.StatusMethodNotAllowed, "method %s is not allowed", r.Method)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err != nil {
+		var he *httpError
+		if !errors.As(err, &he) {
+			log.Printf("scoreserver: %v", err)
+			he = newHTTPError(http.StatusInternalServerError, "internal error")
+		}
+		w.WriteHeader(he.code)
+		json.NewEncoder(w).Encode(map[string]string{"error": he.msg})
+		return
+	}
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+// list returns the top entries. The query can have n, and difficulty,
+// endless and seed to filter the entries.
+func (s *scoreServer) list(r *http.Request) ([]leaderboard.Entry, error) {
+	q := r.URL.Query()
+	n := s.top
+	if v := q.Get("n"); v != "" {
+		i, err := strconv.Atoi(v)
+		if err != nil || i < 1 || i > maxTop {
+			return nil, newHTTPError(http.StatusBadRequest, "n must be from 1 to %d", maxTop)
+		}
+		n = i
+	}
+
+	var filters []func(e leaderboard.Entry) bool
+	if v := q.Get("difficulty"); v != "" {
+		d, err := parseDifficulty(v)
+		if err != nil {
+			return nil, newHTTPError(http.StatusBadRequest, "%v", err)
+		}
+		filters = append(filters, func(e leaderboard.Entry) bool {
+			return e.Difficulty == d
+		})
+	}
+	if v := q.Get("endless"); v != "" {
+		endless, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, newHTTPError(http.StatusBadRequest, "wrong endless %q", v)
+		}
+		filters = append(filters, func(e leaderboard.Entry) bool {
+			return e.Endless == endless
+		})
+	}
+	if v := q.Get("seed"); v != "" {
+		seed, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return nil, newHTTPError(http.StatusBadRequest, "wrong seed %q", v)
+		}
+		filters = append(filters, func(e leaderboard.Entry) bool {
+			return e.Seed == seed
+		})
+	}
+
+	return s.store.top(n, func(e leaderboard.Entry) bool {
+		for _, f := range filters {
+			if !f(e) {
+				return false
+			}
+		}
+		return true
+	}), nil
+}
+
+// submit checks the submission against its replay and stores it.
+func (s *scoreServer) submit(r *http.Request) (leaderboard.Entry, error) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxSubmissionSize+1))
+	if err != nil {
+		return leaderboard.Entry{}, err
+	}
+	if len(body) > maxSubmissionSize {
+		return leaderboard.Entry{}, newHTTPError(http.StatusRequestEntityTooLarge, "too large")
+	}
+	if len(s.key) > 0 && !leaderboard.Verify(s.key, body, r.Header.Get(leaderboard.SignatureHeader)) {
+		return leaderboard.Entry{}, newHTTPError(http.StatusUnauthorized, "wrong signature")
+	}
+
+	var sub leaderboard.Submission
+	if err := json.Unmarshal(body, &sub); err != nil {
+		return leaderboard.Entry{}, newHTTPError(http.StatusBadRequest, "invalid submission: %v", err)
+	}
+	if leaderboard.HashReplay(sub.Replay) != sub.ReplayHash {
+		return leaderboard.Entry{}, newHTTPError(http.StatusBadRequest, "the replay doesn't match the hash")
+	}
+	replay := &sim.Replay{}
+	if err := replay.UnmarshalBinary(sub.Replay); err != nil {
+		return leaderboard.Entry{}, newHTTPError(http.StatusBadRequest, "%v", err)
+	}
+	if replay.Seed != sub.Seed || replay.Difficulty != sub.Difficulty || replay.Endless != sub.Endless {
+		return leaderboard.Entry{}, newHTTPError(http.StatusBadRequest, "the replay is of another run")
+	}
+	if replay.StartStage != 0 {
+		return leaderboard.Entry{}, newHTTPError(http.StatusBadRequest, "practice runs are not scored")
+	}
+	if replay.Route != sim.RouteHash() {
+		return leaderboard.Entry{}, newHTTPError(http.StatusUnprocessableEntity, "the replay was played on another route: the game and the server have different stages.json")
+	}
+
+	w, err := sim.Verify(replay, sim.Claim{Distance: sub.Distance, Frame: sub.Frame})
+	if err != nil {
+		return leaderboard.Entry{}, newHTTPError(http.StatusUnprocessableEntity, "%v", err)
+	}
+	if w.Location != sub.Island {
+		return leaderboard.Entry{}, newHTTPError(http.StatusUnprocessableEntity, "the run reached %s, not %s", w.Location, sub.Island)
+	}
+
+	name := strings.TrimSpace(sub.Name)
+	if name == "" {
+		name = "名無し"
+	}
+	if utf8.RuneCountInString(name) > maxNameLength {
+		name = string([]rune(name)[:maxNameLength])
+	}
+	e, err := s.store.add(leaderboard.Entry{
+		Name:       name,
+		Distance:   w.Distance(),
+		Island:     w.Location,
+		Seed:       replay.Seed,
+		Difficulty: replay.Difficulty,
+		Endless:    replay.Endless,
+		Frames:     w.Frame,
+		ReplayHash: sub.ReplayHash,
+		Time:       s.now().UTC(),
+	}, sub.Replay)
+	if errors.Is(err, errDuplicateScore) {
+		return leaderboard.Entry{}, newHTTPError(http.StatusConflict, "%v", err)
+	}
+	if err != nil {
+		return leaderboard.Entry{}, err
+	}
+	log.Printf("scoreserver: %s %dm %s (#%d)", e.Name, e.Distance, e.Island, e.ID)
+	return e, nil
+}
+
+func runScoreServer(args []string) error {
+	// Parse flags
+	flag := flag.NewFlagSet("scoreserver", flag.ExitOnError)
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: go run ./tool scoreserver [arguments]")
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+
+	addr := flag.String("http", "localhost:8082", "HTTP service address")
+	data := flag.String("data", "scores.json", "JSON file to store the scores in")
+	key := flag.String("key", "", "shared key to verify the signatures of the submissions with, or none to accept unsigned ones; it ships in the game, so it only keeps out casual submissions")
+	top := flag.Int("top", 10, "number of the entries returned by default")
+	flag.Parse(args)
+
+	if flag.NArg() > 0 {
+		fmt.Fprintln(os.Stderr, "unexpected arguments:", flag.Args())
+		flag.Usage()
+	}
+
+	if *top < 1 || *top > maxTop {
+		return fmt.Errorf("-top must be from 1 to %d", maxTop)
+	}
+	store, err := openScoreStore(*data)
+	if err != nil {
+		return err
+	}
+	if *key == "" {
+		log.Printf("scoreserver: no -key, accepting unsigned submissions")
+	}
+
+	s := &scoreServer{
+		store: store,
+		key:   []byte(*key),
+		top:   *top,
+		now:   time.Now,
+	}
+	log.Printf("scoreserver: listening on http://%s/scores with %d scores in %s", *addr, len(store.records), *data)
+	return http.ListenAndServe(*addr, s)
+}
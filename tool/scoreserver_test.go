@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/shuuuta/shimanuke-chuta/leaderboard"
+	"github.com/shuuuta/shimanuke-chuta/sim"
+)
+
+// testSubmission plays a run at random and returns its submission.
+func testSubmission(t *testing.T, seed uint64) leaderboard.Submission {
+	t.Helper()
+	c := sim.Config{Seed: seed}
+	w := sim.New(c)
+	r := &sim.Replay{Route: sim.RouteHash(), Seed: seed}
+	p := &randomController{rng: rand.New(rand.NewPCG(seed, 0))}
+	for !w.Over && !w.Goal {
+		in := p.Input(w)
+		r.Record(in)
+		w.Step(in)
+	}
+	b, err := r.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return leaderboard.Submission{
+		Name:       "チュータ",
+		Distance:   w.Distance(),
+		Island:     w.Location,
+		Frame:      w.Frame,
+		Seed:       seed,
+		Replay:     b,
+		ReplayHash: leaderboard.HashReplay(b),
+	}
+}
+
+func postScore(t *testing.T, url string, key []byte, sub leaderboard.Submission) int {
+	t.Helper()
+	body, err := json.Marshal(sub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := http.NewRequest(http.MethodPost, url+"/scores", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set(leaderboard.SignatureHeader, leaderboard.Sign(key, body))
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	return res.StatusCode
+}
+
+func TestScoreServer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scores.json")
+	store, err := openScoreStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := []byte("secret")
+	s := httptest.NewServer(&scoreServer{
+		store: store,
+		key:   key,
+		top:   10,
+		now:   time.Now,
+	})
+	defer s.Close()
+
+	a := testSubmission(t, 1)
+	b := testSubmission(t, 2)
+
+	forged := a
+	forged.Distance += 1000
+	unfinished := a
+	var r sim.Replay
+	if err := r.UnmarshalBinary(a.Replay); err != nil {
+		t.Fatal(err)
+	}
+	r.Route++
+	otherRoute := a
+	otherRoute.Replay, _ = r.MarshalBinary()
+	otherRoute.ReplayHash = leaderboard.HashReplay(otherRoute.Replay)
+	r.Route--
+	r.Inputs = r.Inputs[:len(r.Inputs)-1]
+	unfinished.Replay, _ = r.MarshalBinary()
+	unfinished.ReplayHash = leaderboard.HashReplay(unfinished.Replay)
+	wrongHash := b
+	wrongHash.ReplayHash = a.ReplayHash
+
+	for _, tc := range []struct {
+		name string
+		key  []byte
+		sub  leaderboard.Submission
+		want int
+	}{
+		{"valid", key, a, http.StatusCreated},
+		{"duplicate", key, a, http.StatusConflict},
+		{"wrong key", []byte("guess"), b, http.StatusUnauthorized},
+		{"forged distance", key, forged, http.StatusUnprocessableEntity},
+		{"unfinished", key, unfinished, http.StatusUnprocessableEntity},
+		{"other route", key, otherRoute, http.StatusUnprocessableEntity},
+		{"wrong hash", key, wrongHash, http.StatusBadRequest},
+		{"another", key, b, http.StatusCreated},
+	} {
+		if got := postScore(t, s.URL, tc.key, tc.sub); got != tc.want {
+			t.Errorf("%s: status %d, want %d", tc.name, got, tc.want)
+		}
+	}
+
+	res, err := http.Get(s.URL + "/scores?n=5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	var top []leaderboard.Entry
+	if err := json.NewDecoder(res.Body).Decode(&top); err != nil {
+		t.Fatal(err)
+	}
+	if len(top) != 2 || top[0].Distance < top[1].Distance {
+		t.Fatalf("top: %+v", top)
+	}
+
+	// The scores are kept in the file.
+	store, err = openScoreStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := store.top(10, func(leaderboard.Entry) bool { return true }); len(got) != 2 {
+		t.Errorf("reopened store has %d scores, want 2", len(got))
+	}
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"cmp"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"runtime"
+	"slices"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/shuuuta/shimanuke-chuta/sim"
+)
+
+// balanceConfig is how the runs of the balance simulation are played.
+type balanceConfig struct {
+	runs      int
+	seed      uint64
+	policy    string
+	config    sim.Config
+	maxFrames int
+}
+
+// killerKey is the configuration of what the player has hit.
+type killerKey struct {
+	stage    string
+	gap      int // 0 for the edge of the screen or an obstacle
+	obstacle string
+	interval int
+	shift    int
+	wave     int
+}
+
+func (k killerKey) String() string {
+	wave := "→"
+	if k.wave < 0 {
+		wave = "←"
+	}
+	if k.obstacle != "" {
+		return fmt.Sprintf("%s\t-\t-\t%s\t%s", k.obstacle, wave, k.stage)
+	}
+	if k.gap == 0 {
+		return fmt.Sprintf("edge\t-\t-\t%s\t%s", wave, k.stage)
+	}
+	return fmt.Sprintf("%d\t%d\t%+d\t%s\t%s", k.gap, k.interval, k.shift, wave, k.stage)
+}
+
+type stageStats struct {
+	name    string
+	reached int
+	deaths  int
+	// distance is the sum of the distances of the deaths in the stage.
+	distance int
+}
+
+// balanceReport is the result of the balance simulation.
+type balanceReport struct {
+	runs     int
+	goals    int
+	distance int
+	stages   []*stageStats
+	killers  map[killerKey]int
+}
+
+// runResult is the end of a run.
+type runResult struct {
+	world  *sim.World
+	killer *killerKey
+}
+
+// controller returns the player of a run.
+func (c balanceConfig) controller(seed uint64) (sim.Controller, error) {
+	switch c.policy {
+	case "bot":
+		return sim.NewBot(), nil
+	case "random":
+		return &randomController{rng: rand.New(rand.NewPCG(seed, 0))}, nil
+	}
+	return nil, fmt.Errorf("unknown policy %q", c.policy)
+}
+
+// randomController strokes at random, 4 times a second on average.
+type randomController struct {
+	rng *rand.Rand
+}
+
+func (r *randomController) Input(*sim.World) sim.Input {
+	switch r.rng.IntN(30) {
+	case 0:
+		return sim.Input{Left: true}
+	case 1:
+		return sim.Input{Right: true}
+	}
+	return sim.Input{}
+}
+
+func (c balanceConfig) play(seed uint64) (runResult, error) {
+	ctrl, err := c.controller(seed)
+	if err != nil {
+		return runResult{}, err
+	}
+	config := c.config
+	config.Seed = seed
+	w := sim.New(config)
+	for !w.Over && !w.Goal && w.Frame < c.maxFrames {
+		w.Step(ctrl.Input(w))
+	}
+
+	r := runResult{world: w}
+	if w.Over {
+		k := killerKey{stage: w.Location, wave: w.WaveDirection()}
+		if s := w.Killer; s != nil {
+			k.gap = s.Gap
+			k.interval = w.SurfInterval
+			k.shift = s.Shift
+		}
+		if o := w.KillerObstacle; o != nil {
+			k.obstacle = o.Kind.String()
+		}
+		r.killer = &k
+	}
+	return r, nil
+}
+
+// balance plays the runs in parallel and sums them up.
+func balance(c balanceConfig) (*balanceReport, error) {
+	results := make([]runResult, c.runs)
+	errs := make([]error, c.runs)
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, runtime.GOMAXPROCS(0))
+	for i := range results {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i], errs[i] = c.play(c.seed + uint64(i))
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	rep := &balanceReport{
+		runs:    c.runs,
+		killers: map[killerKey]int{},
+	}
+	// The route of the run that has gone the furthest has all the stages.
+	var route sim.Stages
+	for _, r := range results {
+		if len(r.world.Stages) > len(route) {
+			route = r.world.Stages
+		}
+	}
+	for _, s := range route {
+		rep.stages = append(rep.stages, &stageStats{name: s.Name})
+	}
+
+	for _, r := range results {
+		w := r.world
+		rep.distance += w.Distance()
+		if w.Goal {
+			rep.goals++
+		}
+		for _, st := range rep.stages {
+			st.reached++
+			if st.name != w.Location {
+				continue
+			}
+			if r.killer != nil {
+				st.deaths++
+				st.distance += w.Distance()
+				rep.killers[*r.killer]++
+			}
+			break
+		}
+	}
+	return rep, nil
+}
+
+func (rep *balanceReport) print(out io.Writer, top int) {
+	// The names of the stages are at the end, as tabwriter doesn't know the
+	// width of Japanese letters.
+	tw := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "reached\tdeaths\tdeath rate\tavg death km\tstage")
+	for _, st := range rep.stages {
+		rate, avg := "-", "-"
+		if st.reached > 0 {
+			rate = fmt.Sprintf("%.1f%%", float64(st.deaths)*100/float64(st.reached))
+		}
+		if st.deaths > 0 {
+			avg = fmt.Sprintf("%.1f", float64(st.distance)/float64(st.deaths)/1000)
+		}
+		fmt.Fprintf(tw, "%d\t%d\t%s\t%s\t%s\n", st.reached, st.deaths, rate, avg, st.name)
+	}
+	tw.Flush()
+
+	fmt.Fprintf(out, "\ngoal: %d/%d, avg distance: %.1fkm\n", rep.goals, rep.runs, float64(rep.distance)/float64(rep.runs)/1000)
+
+	if len(rep.killers) == 0 {
+		return
+	}
+	keys := make([]killerKey, 0, len(rep.killers))
+	for k := range rep.killers {
+		keys = append(keys, k)
+	}
+	slices.SortFunc(keys, func(a, b killerKey) int {
+		if c := cmp.Compare(rep.killers[b], rep.killers[a]); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.String(), b.String())
+	})
+
+	fmt.Fprintln(out, "\ndeadliest surfs and obstacles:")
+	tw = tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "deaths\tgap\tinterval\tshift\twave\tstage")
+	for _, k := range keys[:min(top, len(keys))] {
+		fmt.Fprintf(tw, "%d\t%s\n", rep.killers[k], k)
+	}
+	tw.Flush()
+}
+
+func parseDifficulty(s string) (sim.Difficulty, error) {
+	for _, d := range sim.Difficulties {
+		if d.String() == s {
+			return d, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown difficulty %q", s)
+}
+
+func simulate(args []string) error {
+	// Parse flags
+	flag := flag.NewFlagSet("sim", flag.ExitOnError)
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: go run ./tool sim [arguments]")
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+
+	runs := flag.Int("n", 100, "number of runs")
+	seed := flag.Uint64("seed", 1, "seed of the first run, the others use the following seeds")
+	policy := flag.String("policy", "bot", "who plays: bot or random")
+	difficulty := flag.String("difficulty", "normal", "difficulty: easy, normal or hard")
+	endless := flag.Bool("endless", false, "play the endless mode")
+	stagesFile := flag.String("stages", "", "stages JSON to try instead of sim/stages.json")
+	maxFrames := flag.Int("frames", 60*60*30, "frames to stop a run after")
+	top := flag.Int("top", 10, "number of the deadliest surfs to show")
+	flag.Parse(args)
+
+	if flag.NArg() > 0 {
+		fmt.Fprintln(os.Stderr, "unexpected arguments:", flag.Args())
+		flag.Usage()
+	}
+
+	d, err := parseDifficulty(*difficulty)
+	if err != nil {
+		return err
+	}
+	if *runs < 1 {
+		return fmt.Errorf("wrong number of the runs: -n %d", *runs)
+	}
+	c := balanceConfig{
+		runs:   *runs,
+		seed:   *seed,
+		policy: *policy,
+		config: sim.Config{
+			Difficulty: d,
+			Endless:    *endless,
+		},
+		maxFrames: *maxFrames,
+	}
+	if *stagesFile != "" {
+		b, err := os.ReadFile(*stagesFile)
+		if err != nil {
+			return err
+		}
+		s, err := sim.ParseStages(b)
+		if err != nil {
+			return fmt.Errorf("%s: %w", *stagesFile, err)
+		}
+		c.config.Stages = s
+	}
+
+	rep, err := balance(c)
+	if err != nil {
+		return err
+	}
+	rep.print(os.Stdout, *top)
+	return nil
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestBalance(t *testing.T) {
+	rep, err := balance(balanceConfig{
+		runs:      8,
+		seed:      1,
+		policy:    "random",
+		maxFrames: 60 * 60,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.stages[0].reached != rep.runs {
+		t.Errorf("%d runs reached the first stage, want %d", rep.stages[0].reached, rep.runs)
+	}
+	deaths, killers := 0, 0
+	for _, st := range rep.stages {
+		deaths += st.deaths
+	}
+	for _, n := range rep.killers {
+		killers += n
+	}
+	if deaths+rep.goals > rep.runs || deaths != killers {
+		t.Errorf("%d deaths, %d goals and %d killers in %d runs", deaths, rep.goals, killers, rep.runs)
+	}
+
+	var buf bytes.Buffer
+	rep.print(&buf, 3)
+	if !strings.Contains(buf.String(), "八丈島") {
+		t.Errorf("no stages in the report:\n%s", buf.String())
+	}
+
+	if _, err := balance(balanceConfig{runs: 1, policy: "nope"}); err == nil {
+		t.Error("unknown policy is accepted")
+	}
+}
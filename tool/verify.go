@@ -0,0 +1,84 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/shuuuta/shimanuke-chuta/sim"
+)
+
+// verifyReplay plays the replay file back and prints how the run ended. The
+// claim is checked unless it is nil.
+func verifyReplay(out io.Writer, name string, claim *sim.Claim) error {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	r := &sim.Replay{}
+	if err := r.UnmarshalBinary(b); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+
+	mode := r.Difficulty.String()
+	if r.Endless {
+		mode += ", endless"
+	}
+	if r.StartStage > 0 {
+		mode += fmt.Sprintf(", practice from stage %d", r.StartStage)
+	}
+	fmt.Fprintf(out, "%s: seed %d (%s), %d frames\n", name, r.Seed, mode, len(r.Inputs))
+
+	var w *sim.World
+	if claim != nil {
+		w, err = sim.Verify(r, *claim)
+	} else {
+		w, err = sim.Play(r)
+	}
+	if w == nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	end := "stopped at"
+	switch {
+	case w.Over:
+		end = "sank at"
+	case w.Goal:
+		end = "reached"
+	}
+	fmt.Fprintf(out, "%s %s, %dm at frame %d (%.1fs)\n", end, w.Location, w.Distance(), w.Frame, float64(w.Frame)/60)
+	if err != nil {
+		return err
+	}
+	if claim != nil {
+		fmt.Fprintln(out, "OK: the replay matches the claim")
+	}
+	return nil
+}
+
+func verify(args []string) error {
+	// Parse flags
+	flag := flag.NewFlagSet("verify", flag.ExitOnError)
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: go run ./tool verify [arguments] replay.bin")
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+
+	distance := flag.Int("distance", -1, "claimed distance in meters")
+	frame := flag.Int("frame", -1, "claimed frame the run ended at")
+	flag.Parse(args)
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+	}
+	if (*distance < 0) != (*frame < 0) {
+		return fmt.Errorf("-distance and -frame must be given together")
+	}
+
+	var claim *sim.Claim
+	if *distance >= 0 {
+		claim = &sim.Claim{Distance: *distance, Frame: *frame}
+	}
+	return verifyReplay(os.Stdout, flag.Arg(0), claim)
+}